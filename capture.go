@@ -0,0 +1,81 @@
+package gamepad
+
+import (
+	"context"
+	"errors"
+	. "github.com/gooseclip/pi-gamepad/hid"
+)
+
+// captureTravel is how far an axis must move from where it was first seen to count as pressed, in raw units
+const captureTravel = MaxValue / 2
+
+type inputCapture struct {
+	// First value seen per axis, triggers and some sticks don't rest at zero. Only touched by the event loop.
+	rest map[uint8]int
+	done chan Input
+}
+
+// CaptureNextInput waits for the next button pressed or axis pushed well past its resting value and returns its raw input,
+// mapped or not, for rebinding wizards that build an InputMapping from presses. It returns ctx.Err() if ctx is done first.
+// The input is still delivered as usual, Pause the gamepad meanwhile to keep it from reaching handlers.
+func (g *Gamepad) CaptureNextInput(ctx context.Context) (Input, error) {
+	c := &inputCapture{rest: make(map[uint8]int), done: make(chan Input, 1)}
+	g.mu.Lock()
+	g.captures = append(g.captures, c)
+	g.mu.Unlock()
+
+	select {
+	case in := <-c.done:
+		return in, nil
+	case <-ctx.Done():
+		g.removeCapture(c)
+		return Input{}, ctx.Err()
+	case <-g.ctx.Done():
+		g.removeCapture(c)
+		return Input{}, errors.New("gamepad closed")
+	}
+}
+
+func (g *Gamepad) removeCapture(c *inputCapture) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, other := range g.captures {
+		if other == c {
+			g.captures = append(g.captures[:i], g.captures[i+1:]...)
+			return
+		}
+	}
+}
+
+// captureInput hands a raw input to the waiting captures it counts as pressed for
+func (g *Gamepad) captureInput(in Input, value int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	waiting := g.captures[:0]
+	for _, c := range g.captures {
+		if !c.pressed(in, value) {
+			waiting = append(waiting, c)
+			continue
+		}
+		c.done <- in
+	}
+	for i := len(waiting); i < len(g.captures); i++ {
+		g.captures[i] = nil
+	}
+	g.captures = waiting
+}
+
+func (c *inputCapture) pressed(in Input, value int) bool {
+	if in.Type == InputTypeButton {
+		return value > 0
+	}
+
+	rest, seen := c.rest[in.Value]
+	if !seen {
+		c.rest[in.Value] = value
+		return false
+	}
+	travel := value - rest
+	return travel > captureTravel || travel < -captureTravel
+}
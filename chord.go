@@ -0,0 +1,68 @@
+package gamepad
+
+import (
+	"context"
+	"errors"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"time"
+)
+
+// WaitChord blocks until every button in buttons is down together, with all of them pressed within the given window of each other.
+// It returns ctx.Err() if ctx is done first. Buttons already down when it's called only count once pressed again.
+func (g *Gamepad) WaitChord(ctx context.Context, buttons []Resolved, within time.Duration) error {
+	if len(buttons) == 0 {
+		return errors.New("empty chord")
+	}
+
+	events, unsubscribe := g.Subscribe()
+	defer unsubscribe()
+
+	pressed := make(map[Resolved]time.Time, len(buttons))
+	wanted := make(map[Resolved]bool, len(buttons))
+	for _, b := range buttons {
+		wanted[b] = true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("gamepad closed")
+			}
+			if e.Kind != ButtonInput || !wanted[e.Button] {
+				continue
+			}
+
+			switch e.Event {
+			case DownEvent:
+				if _, down := pressed[e.Button]; !down {
+					pressed[e.Button] = time.Now()
+				}
+			case UpEvent:
+				delete(pressed, e.Button)
+				continue
+			default:
+				continue
+			}
+
+			if len(pressed) < len(wanted) {
+				continue
+			}
+
+			var first, last time.Time
+			for _, t := range pressed {
+				if first.IsZero() || t.Before(first) {
+					first = t
+				}
+				if t.After(last) {
+					last = t
+				}
+			}
+			if last.Sub(first) <= within {
+				return nil
+			}
+		}
+	}
+}
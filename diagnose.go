@@ -0,0 +1,114 @@
+package gamepad
+
+import (
+	"context"
+	"fmt"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"time"
+)
+
+// DiagnoseTiming watches this many presses
+const diagnosePresses = 5
+
+// TimingReport compares measured presses with the configured click and hold durations, see DiagnoseTiming
+type TimingReport struct {
+	// Presses are the measured press durations, in order
+	Presses []time.Duration
+
+	// Average, Shortest and Longest summarize Presses
+	Average  time.Duration
+	Shortest time.Duration
+	Longest  time.Duration
+
+	// The configured thresholds, a press shorter than ClickDuration clicks and one of at least HoldDuration holds
+	ClickDuration time.Duration
+	HoldDuration  time.Duration
+
+	// Presses that were too long to click but too short to hold, they produce only DownEvent and UpEvent
+	Missed int
+
+	// Suggestions are human readable adjustments, empty when the timing matches the thresholds
+	Suggestions []string
+}
+
+// DiagnoseTiming measures the next few presses of any button and compares them with the click and hold durations, for
+// tracking down clicks that don't register. Tap the button the way you normally would. It returns early with what was
+// measured when ctx is done.
+func (g *Gamepad) DiagnoseTiming(ctx context.Context) TimingReport {
+	events, unsubscribe := g.Subscribe()
+	defer unsubscribe()
+
+	report := TimingReport{
+		ClickDuration: g.clickDuration,
+		HoldDuration:  g.holdDuration,
+	}
+
+	down := make(map[Resolved]time.Time)
+	for len(report.Presses) < diagnosePresses {
+		select {
+		case <-ctx.Done():
+			report.summarize()
+			return report
+		case e, ok := <-events:
+			if !ok {
+				report.summarize()
+				return report
+			}
+			if e.Kind != ButtonInput {
+				continue
+			}
+
+			switch e.Event {
+			case DownEvent:
+				if _, pressed := down[e.Button]; !pressed {
+					down[e.Button] = e.When
+				}
+			case UpEvent:
+				if t, pressed := down[e.Button]; pressed {
+					report.Presses = append(report.Presses, e.When.Sub(t))
+					delete(down, e.Button)
+				}
+			}
+		}
+	}
+	report.summarize()
+	return report
+}
+
+func (r *TimingReport) summarize() {
+	if len(r.Presses) == 0 {
+		return
+	}
+
+	var total, longestTap time.Duration
+	r.Shortest = r.Presses[0]
+	for _, p := range r.Presses {
+		total += p
+		if p < r.Shortest {
+			r.Shortest = p
+		}
+		if p > r.Longest {
+			r.Longest = p
+		}
+		if p >= r.ClickDuration && p < r.HoldDuration {
+			r.Missed++
+			if p > longestTap {
+				longestTap = p
+			}
+		}
+	}
+	r.Average = total / time.Duration(len(r.Presses))
+
+	if r.Missed == 0 {
+		return
+	}
+	r.Suggestions = append(r.Suggestions, fmt.Sprintf("%v of %v presses were too long to click but too short to hold, your presses average %v but clickDuration is %v",
+		r.Missed, len(r.Presses), r.Average.Round(time.Millisecond), r.ClickDuration))
+
+	// Leave some headroom above the longest missed tap
+	suggested := (longestTap + 50*time.Millisecond).Round(10 * time.Millisecond)
+	r.Suggestions = append(r.Suggestions, fmt.Sprintf("try WithClickDuration(%v)", suggested))
+	if suggested >= r.HoldDuration {
+		r.Suggestions = append(r.Suggestions, fmt.Sprintf("holdDuration must stay above it, try WithHoldDuration(%v)", suggested+r.HoldDuration-r.ClickDuration))
+	}
+}
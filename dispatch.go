@@ -0,0 +1,50 @@
+package gamepad
+
+import (
+	. "github.com/gooseclip/pi-gamepad/hid"
+)
+
+// buttonQueueSize is how many handler calls a button can have waiting before the next event waits for room
+const buttonQueueSize = 16
+
+// WithPerButtonGoroutines calls each button's handlers on a goroutine of its own, so a slow handler for one button doesn't
+// hold up the others. A button's events are still handled one at a time and in order. Handlers waiting when the gamepad
+// is closed aren't called.
+func WithPerButtonGoroutines() option {
+	return func(gamepad *Gamepad) {
+		gamepad.buttonQueues = make(map[Resolved]chan func())
+	}
+}
+
+// dispatch calls a handler for a button, on the button's goroutine when WithPerButtonGoroutines is used
+func (g *Gamepad) dispatch(resolved Resolved, f func()) {
+	if g.buttonQueues == nil {
+		f()
+		return
+	}
+
+	g.mu.Lock()
+	queue, ok := g.buttonQueues[resolved]
+	if !ok {
+		queue = make(chan func(), buttonQueueSize)
+		g.buttonQueues[resolved] = queue
+		go g.runQueue(queue)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-g.ctx.Done():
+	case queue <- f:
+	}
+}
+
+func (g *Gamepad) runQueue(queue <-chan func()) {
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case f := <-queue:
+			f()
+		}
+	}
+}
@@ -3,22 +3,23 @@ package main
 import (
 	"context"
 	"github.com/gooseclip/pi-gamepad"
+	"github.com/gooseclip/pi-gamepad/hid"
 	"log"
 )
 
 func main() {
 
-	// Setup a custom device name and configure inputs.
+	// Setup a custom mapping for whichever device is found.
 	// Note: Inputs can be discovered by first setting up an empty mapping and using WithDebug
 	// to determine the input mapping.
-	gamepad.DriverMapping["My Custom Driver Name"] = gamepad.InputMapping{
+	mapping := hid.InputMapping{
 		//DPadXAxis:                 3,
-		gamepad.Input{Type: gamepad.InputTypeAxis, Value: 2}: gamepad.DPadXAxis,
+		hid.Input{Type: hid.InputTypeAxis, Value: 2}: hid.DPadXAxis,
 		//DPadYAxis:                 2,
-		gamepad.Input{Type: gamepad.InputTypeAxis, Value: 3}: gamepad.DPadYAxis,
+		hid.Input{Type: hid.InputTypeAxis, Value: 3}: hid.DPadYAxis,
 	}
 
-	gp, err := gamepad.NewGamepad(context.Background())
+	gp, err := gamepad.NewGamepad(context.Background(), gamepad.WithMapping(mapping), gamepad.WithAnyDevice())
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,72 @@
+package gamepad
+
+import (
+	. "github.com/gooseclip/pi-gamepad/hid"
+)
+
+type eventFilter func(e InputEvent) (InputEvent, bool)
+
+// WithEventFilter passes every event through f before it's dispatched to handlers and subscribers. Returning false drops
+// the event, otherwise the returned event is dispatched in its place. A button event may be changed into another button
+// or event and goes to that button's handlers, an axis event keeps its kind and group and takes X and Y from the result.
+// The button state machine still sees the physical input, so e.g. a dropped DownEvent doesn't stop the ClickEvent after it.
+func WithEventFilter(f eventFilter) option {
+	return func(gamepad *Gamepad) {
+		gamepad.filter = f
+	}
+}
+
+// filterButton drops a button event while paused and applies the event filter to it, returning the button, its binding and event to dispatch
+func (g *Gamepad) filterButton(resolved Resolved, btn *button, event ButtonEvent) (Resolved, *button, ButtonEvent, bool) {
+	if g.Paused() {
+		return resolved, btn, event, false
+	}
+	if g.filter == nil {
+		return resolved, btn, event, true
+	}
+
+	e, ok := g.filter(InputEvent{Kind: ButtonInput, Button: resolved, Event: event})
+	if !ok || e.Kind != ButtonInput {
+		return resolved, btn, event, false
+	}
+	if e.Button != resolved {
+		ref := g.buttonRef(e.Button)
+		if ref == nil {
+			return resolved, btn, event, false
+		}
+		g.mu.Lock()
+		btn = *ref
+		g.mu.Unlock()
+	}
+	return e.Button, btn, e.Event, true
+}
+
+// filterAxis drops axis values while paused and applies the event filter to them
+func (g *Gamepad) filterAxis(group AxisGroup, x, y float64) (float64, float64, bool) {
+	if g.Paused() {
+		return x, y, false
+	}
+	if g.filter == nil {
+		return x, y, true
+	}
+
+	e, ok := g.filter(InputEvent{Kind: AxisInput, Group: group, X: float32(x), Y: float32(y)})
+	if !ok || e.Kind != AxisInput {
+		return x, y, false
+	}
+	return float64(e.X), float64(e.Y), true
+}
+
+// passes reports whether a button event reaches the handlers outside the event flow, like OnButtonRaw and OnHoldRelease.
+// They're held back while paused and when the event filter drops the event, a changed event is still delivered as the physical one.
+func (g *Gamepad) passes(resolved Resolved, event ButtonEvent) bool {
+	if g.Paused() {
+		return false
+	}
+	if g.filter == nil {
+		return true
+	}
+
+	e, ok := g.filter(InputEvent{Kind: ButtonInput, Button: resolved, Event: event})
+	return ok && e.Kind == ButtonInput
+}
@@ -0,0 +1,85 @@
+package gamepad
+
+import (
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"sort"
+	"time"
+)
+
+// Frame is the input of one frame interval, see OnFrame
+type Frame struct {
+	// Pressed are the buttons down at the end of the frame, in Resolved order
+	Pressed []Resolved
+
+	// Axes are the last normalized values of each group, triggers carry theirs in x
+	Axes map[AxisGroup][2]float32
+
+	// Events are the button events that happened during the frame, in order
+	Events map[Resolved][]ButtonEvent
+}
+
+type frameHandler func(f Frame)
+
+// WithFrameRate batches input into frames delivered to OnFrame hz times a second, for fixed rate game loops
+func WithFrameRate(hz float64) option {
+	return func(gamepad *Gamepad) {
+		gamepad.frameRate = hz
+	}
+}
+
+// OnFrame subscribes to batched input, one call per frame at the rate set WithFrameRate.
+// It's called from its own goroutine, not the event loop.
+func (g *Gamepad) OnFrame(h frameHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.frameHandler = h
+}
+
+// accumulate records a published event into the current frame, the caller holds g.mu
+func (g *Gamepad) accumulate(e InputEvent) {
+	if g.frameRate <= 0 {
+		return
+	}
+
+	switch e.Kind {
+	case ButtonInput:
+		g.frameEvents[e.Button] = append(g.frameEvents[e.Button], e.Event)
+	case AxisInput:
+		g.frameAxes[e.Group] = [2]float32{e.X, e.Y}
+	}
+}
+
+func (g *Gamepad) runFrames() {
+	t := time.NewTicker(time.Duration(float64(time.Second) / g.frameRate))
+	defer t.Stop()
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		g.mu.Lock()
+		h := g.frameHandler
+		f := Frame{
+			Axes:   make(map[AxisGroup][2]float32, len(g.frameAxes)),
+			Events: g.frameEvents,
+		}
+		for group, v := range g.frameAxes {
+			f.Axes[group] = v
+		}
+		for b, state := range g.buttonStates {
+			if state.lastPosition == DownPosition {
+				f.Pressed = append(f.Pressed, b)
+			}
+		}
+		g.frameEvents = make(map[Resolved][]ButtonEvent)
+		g.mu.Unlock()
+
+		sort.Slice(f.Pressed, func(i, j int) bool { return f.Pressed[i] < f.Pressed[j] })
+		if h != nil {
+			h(f)
+		}
+	}
+}
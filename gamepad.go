@@ -5,7 +5,11 @@ import (
 	"errors"
 	"fmt"
 	. "github.com/gooseclip/pi-gamepad/hid"
+	"io"
 	"log"
+	"math"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -16,6 +20,14 @@ const (
 	DownPosition
 )
 
+// ButtonEvent is produced by a button press. A press always starts with DownEvent and ends with UpEvent,
+// in between it is classified as either a tap or a hold, never both:
+//
+//	Down -> (released before the hold duration)        -> Up -> Click (if within the click duration)
+//	Down -> (hold duration reached) -> PressAndHold, Hold -> Up
+//
+// A press that produced PressAndHold never produces Click. A button nothing subscribes to holds for is never held,
+// its presses only depend on the click duration.
 type ButtonEvent int
 
 const (
@@ -23,6 +35,7 @@ const (
 	DownEvent
 	ClickEvent
 	HoldEvent
+	PressAndHoldEvent
 )
 
 func (e ButtonEvent) String() string {
@@ -35,31 +48,344 @@ func (e ButtonEvent) String() string {
 		return "Click"
 	case HoldEvent:
 		return "Hold"
+	case PressAndHoldEvent:
+		return "PressAndHold"
 	}
 	return "Unknown"
 }
 
+// AxisGroup identifies a logical analog control
+type AxisGroup int
+
+const (
+	DPadGroup AxisGroup = iota
+	LeftStickGroup
+	RightStickGroup
+	LeftTriggerGroup
+	RightTriggerGroup
+	TouchpadGroup
+)
+
+func (a AxisGroup) String() string {
+	switch a {
+	case DPadGroup:
+		return "DPad"
+	case LeftStickGroup:
+		return "LeftStick"
+	case RightStickGroup:
+		return "RightStick"
+	case LeftTriggerGroup:
+		return "LeftTrigger"
+	case RightTriggerGroup:
+		return "RightTrigger"
+	case TouchpadGroup:
+		return "Touchpad"
+	}
+	return "Unknown"
+}
+
+// Direction8 is a stick position snapped to the eight compass directions
+type Direction8 int
+
+const (
+	Neutral Direction8 = iota
+	North
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+func (d Direction8) String() string {
+	switch d {
+	case Neutral:
+		return "Neutral"
+	case North:
+		return "N"
+	case NorthEast:
+		return "NE"
+	case East:
+		return "E"
+	case SouthEast:
+		return "SE"
+	case South:
+		return "S"
+	case SouthWest:
+		return "SW"
+	case West:
+		return "W"
+	case NorthWest:
+		return "NW"
+	}
+	return "Unknown"
+}
+
+type InputKind int
+
+const (
+	ButtonInput InputKind = iota
+	AxisInput
+)
+
+// InputEvent is a single event produced by the gamepad, tagged by Kind. Every field is always encoded, as zero is a
+// meaningful value for most of them, e.g. CrossButton or a centered stick.
+type InputEvent struct {
+	Kind InputKind `json:"kind"`
+
+	// Set for ButtonInput
+	Button Resolved    `json:"button"`
+	Event  ButtonEvent `json:"event"`
+
+	// Set for AxisInput, triggers report their 0..1 value in X
+	Group AxisGroup `json:"group"`
+	X     float32   `json:"x"`
+	Y     float32   `json:"y"`
+
+	// When the event happened, see WithTimestampSource
+	When time.Time `json:"when"`
+
+	// Seq numbers events in dispatch order from 1, with no gaps. A gap or step back on the receiving end means events were
+	// dropped or reordered on the way, e.g. by a subscriber that fell behind.
+	Seq uint64 `json:"seq"`
+}
+
+// TimestampSource selects what InputEvent.When is based on
+type TimestampSource int
+
+const (
+	// DeviceTimestamps uses the device's own event times, anchored at the time the device connected.
+	// They reflect when the input happened, even if the event loop was behind, but drift from the local clock over time.
+	DeviceTimestamps TimestampSource = iota
+	// ClockTimestamps stamps events with time.Now() as they are dispatched, for correlating with the local clock.
+	// It includes any latency between the device and dispatch.
+	ClockTimestamps
+)
+
 const (
 	defaultClickDuration = time.Millisecond * 300
 	defaultHoldDuration  = time.Millisecond * 800
+	default8WayDeadzone  = 0.5
+	axisRampInterval     = time.Millisecond * 16
+
+	// A stick sample held back by WithSpikeFilter is let through after this long without a sample contradicting it
+	spikeHoldTime = time.Millisecond * 50
+
+	// A stick this close to center counts as centered for relative motion
+	deltaCenterRadius = 0.05
+
+	// Rumble strength goes through this gamma by default, lifting the low end motors barely spin at
+	defaultRumbleGamma = 0.5
+
+	// Right stick mouse defaults, a squared response up to this many pixels a second
+	defaultMouseExponent = 2
+	defaultMouseSpeed    = 1000
+
+	// Both sticks count as aligned past this magnitude, pointing within this many degrees of each other
+	defaultAlignMagnitude = 0.5
+	defaultAlignTolerance = 20
+
+	// Trigger stages, a stage is left once the trigger drops stageHysteresis below where it was entered
+	defaultSoftThreshold = 0.5
+	fullThreshold        = 0.95
+	stageHysteresis      = 0.05
+
+	// A trigger at or below this, in 0..1 of its travel, counts as released, some triggers never settle exactly at rest
+	triggerThreshold = 0.05
+
+	// The raw value of a released trigger, triggers span the full axis range like the sticks
+	triggerRest = -MaxValue
 )
 
 type Gamepad struct {
-	ctx           context.Context
-	cancel        context.CancelFunc
-	device        *HID
-	invertY       bool
-	axisCache     map[Resolved]int
-	clickDuration time.Duration
-	holdDuration  time.Duration
-	inputMapping  InputMapping
-	debug         bool
+	ctx             context.Context
+	cancel          context.CancelFunc
+	device          *HID
+	deviceCancel    context.CancelFunc
+	reconnect       time.Duration
+	gracePeriod     time.Duration
+	connectAttempts int
+	connectDelay    time.Duration
+	normalizeDPad   bool
+	handlerTimeout  time.Duration
+	noDupFilter     bool
+	timestamps      TimestampSource
+	rumbleCurve     func(float32) float32
+	manualStart     bool
+	socketPath      string
+	oscAddr         string
+	recorder        *Recorder
+	binaryLog       *BinaryLog
+	replay          io.Reader
+	replayConfig    ReplayConfig
+	invertY         bool
+	axisCache       map[Resolved]int
+	clickDuration   time.Duration
+	holdDuration    time.Duration
+	holdRepeat      time.Duration
+	inputMapping    InputMapping
+	customMapping   InputMapping
+	customAxes      AxisMapping
+	faceRotation    int
+	strictMapping   bool
+	axisMapping     AxisMapping
+	debug           bool
+	connectConfig   ConnectConfig
+	errorHandler    errorHandler
+	connHandler     connectionHandler
+	everything      inputEventHandler
+	axisHandler     axisChangeHandler
+	pedalHandler    pedalHandler
+	changedHandler  buttonsChangedHandler
+	filter          eventFilter
+
+	// Analog triggers, delivered alongside the trigger's button events
+	l2AxisHandler triggerHandler
+	r2AxisHandler triggerHandler
+
+	// Two stage triggers, the stages are only touched by the event loop
+	l2StageHandler triggerStageHandler
+	r2StageHandler triggerStageHandler
+	triggerStages  map[AxisGroup]int
+	softThreshold  float32
+
+	// Travel, in 0..1, a trigger is pressed above and released at or below as a button, nil uses triggerThreshold for both
+	triggerHysteresis *[2]float32
+
+	mu           sync.Mutex
+	buttonStates map[Resolved]*buttonState
+	toggles      map[Resolved]*toggle
+	stickyHolds  map[Resolved]*stickyHold
+	modifiers    map[Resolved][]modifierBinding
+	holdReleases map[Resolved]holdReleaseHandler
+	holdTiers    map[Resolved][]HoldTier
+	holdWanted   map[Resolved]int // Helpers watching for a button's hold events, e.g. a running RadialMenu
+	captures     []*inputCapture  // Waiting CaptureNextInput calls
+
+	// Handler calls waiting per button, nil calls handlers on the goroutine delivering the event
+	buttonQueues map[Resolved]chan func()
+
+	// Set for the gamepads a GamepadManager hands out, ready is called once the gamepad is set up and before its event
+	// loop starts, lost once the device is gone and the event loop stopped
+	lifecycle    func(e LifecycleEvent)
+	ready        func()
+	lost         func()
+	rawHandlers  map[Resolved]rawButtonHandler
+	pressCounts  map[Resolved]int
+	lastActivity time.Time
+	lastWhen     time.Time // Device time of the last event
+	seq          uint64    // Sequence number of the last published event
+	deviceStart  time.Time
+	dropped      [2]uint64 // Buttons and axes dropped by earlier devices
+	subscribers  map[*subscriber]struct{}
+	subDropped   uint64        // Events dropped for subscribers, including ones gone since
+	closing      bool          // Close is draining the device
+	drained      chan struct{} // Closed by the event loop once a draining device has delivered everything
+
+	// Turbo, the buttons in turbo mode and the toggle button that latches them
+	turbo         map[Resolved]bool
+	turboInterval time.Duration
+	turboToggle   *Resolved
+	turboArmed    bool
+
+	// Frames, accumulated between ticks
+	frameRate    float64
+	frameHandler frameHandler
+	frameAxes    map[AxisGroup][2]float32
+	frameEvents  map[Resolved][]ButtonEvent
+
+	// flushCh asks the event loop to re-emit the cached axes, the request is closed once done
+	flushCh chan chan struct{}
+
+	// reconnectCh asks the event loop to swap the device, the result of connecting is sent back
+	reconnectCh chan chan<- error
+
+	// Pausing, switched by the event loop through pauseCh
+	pauseCh      chan pauseRequest
+	paused       bool
+	pauseNeutral bool
+
+	// Trigger positions, tracked apart from the button state machine
+	triggerPositions map[Resolved]ButtonPosition
+
+	// Inputs currently down per button, a button mapped from several inputs is down while any of them is.
+	// Only touched by the event loop.
+	inputsDown map[Resolved]map[Input]bool
+
+	// Stick rotation in radians, keyed by the stick's x axis
+	rotations map[Resolved]float64
+
+	// Independent x and y deadzones per group
+	axisDeadzones map[AxisGroup][2]float64
+
+	// Angular tolerance in radians within which a group snaps to the nearest of the 8 directions
+	snaps map[AxisGroup]float64
+
+	// Noise floor of each axis as reported by the device, normalized
+	axisNoise map[Resolved]float64
+
+	// Output range for direction handlers, nil keeps -1..1
+	outputRange *[2]float64
+
+	// Bounds normalized stick values are clamped to, nil leaves them unclamped
+	clamp *[2]float64
+
+	// Largest normalized change WithSpikeFilter lets through unconfirmed, the samples held back waiting to be
+	// confirmed and when they're let through regardless. Only touched by the event loop.
+	spikeDelta    float64
+	spikeSuspects map[Resolved]int
+	spikeRelease  <-chan time.Time
+
+	// OnSequence registrations, with the buttons down and directions they track, under mu
+	sequences []*sequence
+	seqDown   map[Resolved]bool
+	seqDirs   map[AxisGroup]Direction8
+	seqDir    Direction8 // Direction of the group that changed last
+
+	// WithAutoRecenter learn rate and the center learned per stick
+	recenterRate float64
+	drifts       map[AxisGroup]*drift
 
 	// Movement
 	dpadHandler     directionHandler
 	leftJoyHandler  directionHandler
 	rightJoyHandler directionHandler
 
+	// Movement, snapped to 8 directions
+	leftJoy8WayHandler direction8Handler
+	leftJoy8Way        Direction8
+	deadzone8Way       float32
+
+	// Left stick off center before the deadzone, as last reported
+	leftTouchHandler stickTouchHandler
+	leftTouching     bool
+
+	// Movement, relative to the previous emission
+	leftJoyDeltaHandler directionHandler
+	leftJoyLast         [2]float64
+
+	// Right stick as a mouse, stick is the last processed position under mu
+	mouseExponent float64
+	mouseSpeed    float64
+	rightStick    [2]float64
+
+	// Touchpad, the last position touched
+	touchpadHandler touchpadHandler
+	touchLast       [2]float32
+
+	// Both sticks pushed the same way
+	alignedHandler alignedHandler
+	aligned        bool
+	alignMagnitude float64
+	alignTolerance float64
+
+	// Movement, full precision
+	dpadHandler64     directionHandler64
+	leftJoyHandler64  directionHandler64
+	rightJoyHandler64 directionHandler64
+
 	// Action buttons
 	crossBtn    *button
 	circleBtn   *button
@@ -78,50 +404,211 @@ type Gamepad struct {
 	analogBtn *button
 	ljBtn     *button
 	rjBtn     *button
+	guideBtn  *button
+
+	// DPad directions as buttons
+	dpadUpBtn    *button
+	dpadDownBtn  *button
+	dpadLeftBtn  *button
+	dpadRightBtn *button
 }
 
 type directionHandler func(x, y float32)
 
+type errorHandler func(err error)
+
+type connectionHandler func(connected bool, driver string, reason DisconnectReason)
+
+type inputEventHandler func(e InputEvent)
+
+type axisChangeHandler func(group AxisGroup, x, y float32)
+
+type pedalHandler func(value float32)
+
+type triggerStageHandler func(stage int)
+
+type triggerHandler func(value float32)
+
+type buttonsChangedHandler func(pressed, released []Resolved)
+
+type directionHandler64 func(x, y float64)
+
+type direction8Handler func(dir Direction8)
+
+type alignedHandler func(dir Direction8, magnitude float32)
+
+type touchpadHandler func(x, y float32, touching bool)
+
+type stickTouchHandler func(touching bool)
+
 type button struct {
-	handler      buttonHandler
-	events       []ButtonEvent
+	handler buttonHandler
+	events  []ButtonEvent
+}
+
+type buttonHandler func(event ButtonEvent)
+
+type holdReleaseHandler func(held time.Duration)
+
+// HoldTier is one step of a hold that does more the longer it's held, see OnHoldTiers
+type HoldTier struct {
+	Duration time.Duration
+	Handler  func()
+}
+
+// modifierBinding is a button binding that only applies while the modifier is down
+type modifierBinding struct {
+	modifier Resolved
+	button
+}
+
+// buttonState tracks a physical button regardless of whether a handler is subscribed
+type buttonState struct {
 	lastPosition ButtonPosition
 	downTime     time.Time
 	holdTimer    *time.Timer
+	turboTimer   *time.Timer
+	tierTimers   []*time.Timer
+	held         bool // The current press reached the hold duration
 }
 
-type buttonHandler func(event ButtonEvent)
+type rawButtonHandler func(pos ButtonPosition)
+
+type toggle struct {
+	on      bool
+	handler toggleHandler
+}
+
+type toggleHandler func(on bool)
+
+type stickyHold struct {
+	active  bool
+	handler stickyHoldHandler
+}
+
+type stickyHoldHandler func(active bool)
+
+// State is a snapshot of the state latched by the library
+type State struct {
+	// Toggles holds the latched value of every button configured as a toggle
+	Toggles map[Resolved]bool
+
+	// AnalogMode is the latched analog mode, see WithAnalogAsToggle
+	AnalogMode bool
+
+	// StickyHolds holds whether each sticky hold button is currently active
+	StickyHolds map[Resolved]bool
+}
 
 type option func(*Gamepad)
 
+// newGamepad sets up a gamepad with its defaults and opts applied, without a context or connecting. Options only set
+// fields, so it also serves to read the configuration opts make, see NewGamepadManager.
+func newGamepad(opts ...option) *Gamepad {
+	g := &Gamepad{
+		axisCache:      make(map[Resolved]int),
+		clickDuration:  defaultClickDuration,
+		holdDuration:   defaultHoldDuration,
+		deadzone8Way:   default8WayDeadzone,
+		softThreshold:  defaultSoftThreshold,
+		alignMagnitude: defaultAlignMagnitude,
+		mouseExponent:  defaultMouseExponent,
+		mouseSpeed:     defaultMouseSpeed,
+		alignTolerance: degToRad(defaultAlignTolerance),
+		triggerStages:  make(map[AxisGroup]int),
+		flushCh:        make(chan chan struct{}),
+		reconnectCh:    make(chan chan<- error),
+		pauseCh:        make(chan pauseRequest),
+		drained:        make(chan struct{}),
+		frameAxes:      make(map[AxisGroup][2]float32),
+		frameEvents:    make(map[Resolved][]ButtonEvent),
+		buttonStates:   make(map[Resolved]*buttonState),
+		toggles:        make(map[Resolved]*toggle),
+		stickyHolds:    make(map[Resolved]*stickyHold),
+		turbo:          make(map[Resolved]bool),
+		modifiers:      make(map[Resolved][]modifierBinding),
+		holdReleases:   make(map[Resolved]holdReleaseHandler),
+		holdTiers:      make(map[Resolved][]HoldTier),
+		holdWanted:     make(map[Resolved]int),
+		rawHandlers:    make(map[Resolved]rawButtonHandler),
+		pressCounts:    make(map[Resolved]int),
+		lastActivity:   time.Now(),
+		subscribers:    make(map[*subscriber]struct{}),
+		triggerPositions: map[Resolved]ButtonPosition{
+			L2Axis: UpPosition,
+			R2Axis: UpPosition,
+		},
+		rotations:     make(map[Resolved]float64),
+		inputsDown:    make(map[Resolved]map[Input]bool),
+		axisDeadzones: make(map[AxisGroup][2]float64),
+		snaps:         make(map[AxisGroup]float64),
+		seqDown:       make(map[Resolved]bool),
+		seqDirs:       make(map[AxisGroup]Direction8),
+		clamp:         &[2]float64{-1, 1},
+	}
+
+	for _, o := range opts {
+		o(g)
+	}
+	return g
+}
+
 func NewGamepad(ctx context.Context, opts ...option) (*Gamepad, error) {
+	g := newGamepad(opts...)
 	ctx, cancel := context.WithCancel(ctx)
-	device, err := Connect(ctx)
-	if err != nil {
+	g.ctx, g.cancel = ctx, cancel
+
+	if g.strictMapping && g.customMapping != nil {
+		if err := ValidateMapping(g.customMapping); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	if err := g.connectWithRetry(); err != nil {
 		cancel()
 		return nil, fmt.Errorf("failed to connect with device")
 	}
 
-	g := &Gamepad{
-		ctx:           ctx,
-		cancel:        cancel,
-		device:        device,
-		axisCache:     make(map[Resolved]int),
-		clickDuration: defaultClickDuration,
-		holdDuration:  defaultHoldDuration,
-		inputMapping:  DriverMapping[device.Driver],
+	// Initialize axis cache with zero values and track every button, TouchpadYAxis is the last Resolved
+	for i := CrossButton; i <= TouchpadYAxis; i++ {
+		if i.IsAxis() {
+			g.axisCache[i] = 0
+		} else {
+			g.buttonStates[i] = &buttonState{}
+		}
 	}
+	g.axisCache[L2Axis] = triggerRest
+	g.axisCache[R2Axis] = triggerRest
 
-	for _, o := range opts {
-		o(g)
+	// Track the triggers too, which are axis but treated as buttons
+	g.buttonStates[L2Axis] = &buttonState{}
+	g.buttonStates[R2Axis] = &buttonState{}
+
+	if g.socketPath != "" {
+		if err := g.broadcast(g.socketPath); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to listen on %v: %w", g.socketPath, err)
+		}
 	}
 
-	// Initialize axis cache with zero values
-	for i := DPadXAxis; i <= R2Axis; i++ {
-		g.axisCache[i] = 0
+	if g.oscAddr != "" {
+		if err := g.streamOSC(g.oscAddr); err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to stream OSC to %v: %w", g.oscAddr, err)
+		}
 	}
 
-	go g.handleEvents()
+	if g.frameRate > 0 {
+		go g.runFrames()
+	}
+
+	if g.ready != nil {
+		g.ready()
+	}
+	if !g.manualStart {
+		go g.handleEvents(g.ctx)
+	}
 
 	return g, nil
 }
@@ -150,305 +637,1981 @@ func WithHoldDuration(duration time.Duration) option {
 	}
 }
 
-func (g *Gamepad) Close() error {
-	g.cancel()
-	return nil
+// WithHoldRepeat re-fires HoldEvent every interval after the initial hold until the button is released
+func WithHoldRepeat(interval time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.holdRepeat = interval
+	}
 }
 
-// OnDPad subscribes to dpad events
-func (g *Gamepad) OnDPad(h directionHandler) {
-	g.dpadHandler = h
+// With8WayDeadzone sets the magnitude the stick must pass before 8-way output leaves Neutral - default 0.5
+func With8WayDeadzone(deadzone float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.deadzone8Way = deadzone
+	}
 }
 
-// OnLeftJoystick subscribes to left joystick move events
-func (g *Gamepad) OnLeftJoystick(h directionHandler) {
-	g.leftJoyHandler = h
+// WithManualStart stops NewGamepad from dispatching events, register handlers and then call Run
+func WithManualStart() option {
+	return func(gamepad *Gamepad) {
+		gamepad.manualStart = true
+	}
 }
 
-// OnRightJoystick subscribes to right joystick move events
-func (g *Gamepad) OnRightJoystick(h directionHandler) {
-	g.rightJoyHandler = h
+// WithAutoReconnect looks for a device every interval after a disconnect, whichever controller is found next is used.
+// This also recovers from read errors such as a Mac waking from sleep. Without it the gamepad stops dispatching once
+// the device is gone for any reason, the error is reported through OnError and the disconnect through OnConnectionChange.
+func WithAutoReconnect(interval time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.reconnect = interval
+	}
 }
 
-// OnL1 subscribes to L1 button events
-func (g *Gamepad) OnL1(h buttonHandler, events ...ButtonEvent) {
-	g.l1Btn = &button{
-		handler: h,
-		events:  events,
+// WithGracePeriodBeforeClose makes Close stop reading the device and keep dispatching the events already read for up to d
+// before shutting down, so e.g. a recording ends with the final release rather than mid-press
+func WithGracePeriodBeforeClose(d time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.gracePeriod = d
 	}
 }
 
-// OnR1 subscribes to R1 button events
-func (g *Gamepad) OnR1(h buttonHandler, events ...ButtonEvent) {
-	g.r1Btn = &button{
-		handler: h,
-		events:  events,
+// WithConnectRetry makes NewGamepad retry finding the device up to attempts more times, waiting delay between them.
+// It helps at boot when USB enumeration isn't done yet, retrying stops early if the context is done.
+func WithConnectRetry(attempts int, delay time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectAttempts = attempts
+		gamepad.connectDelay = delay
 	}
 }
 
-// OnL2 subscribes to L2 button events
-func (g *Gamepad) OnL2(h buttonHandler, events ...ButtonEvent) {
-	g.l2Btn = &button{
-		handler: h,
-		events:  events,
+// WithUnixSocketBroadcast serves every event on a Unix domain socket at path, see broadcast for the wire format
+func WithUnixSocketBroadcast(path string) option {
+	return func(gamepad *Gamepad) {
+		gamepad.socketPath = path
 	}
 }
 
-// OnR2 subscribes to R2 button events
-func (g *Gamepad) OnR2(h buttonHandler, events ...ButtonEvent) {
-	g.r2Btn = &button{
-		handler: h,
-		events:  events,
+// WithOSCOutput sends every event as an OSC message to the UDP address addr, e.g. "127.0.0.1:9000" for TouchDesigner,
+// Max or Pd. See streamOSC for the addresses.
+func WithOSCOutput(addr string) option {
+	return func(gamepad *Gamepad) {
+		gamepad.oscAddr = addr
 	}
 }
 
-// OnSelect subscribes to select button events
-func (g *Gamepad) OnSelect(h buttonHandler, events ...ButtonEvent) {
-	g.selectBtn = &button{
-		handler: h,
-		events:  events,
+// WithMapping uses m for this gamepad instead of looking up DriverMapping. Only devices DriverMapping knows are still
+// connected to, add WithAnyDevice for a controller it doesn't. The gamepad keeps its own copy, later changes to m don't reach it.
+// The driver's DriverAxisMapping belongs to its own mapping and isn't applied, give m's axis configs with WithAxisMapping.
+func WithMapping(m InputMapping) option {
+	return func(gamepad *Gamepad) {
+		gamepad.customMapping = m.Copy()
 	}
 }
 
-// OnStart subscribes to start button events
-func (g *Gamepad) OnStart(h buttonHandler, events ...ButtonEvent) {
-	g.startBtn = &button{
-		handler: h,
-		events:  events,
+// WithAxisMapping scales and inverts axis inputs as m describes instead of looking up DriverAxisMapping, an entry takes
+// precedence over the input mapping for the same Input. The gamepad keeps its own copy.
+func WithAxisMapping(m AxisMapping) option {
+	return func(gamepad *Gamepad) {
+		gamepad.customAxes = m.Copy()
 	}
 }
 
-// OnAnalog subscribes to analog button events
-func (g *Gamepad) OnAnalog(h buttonHandler, events ...ButtonEvent) {
-	g.analogBtn = &button{
-		handler: h,
-		events:  events,
+// WithAnyDevice also connects to devices without a DriverMapping entry, for use with WithMapping. Any joystick API device
+// qualifies on Linux, including joysticks and accelerometers, so pair it with WithDeviceID where that matters.
+func WithAnyDevice() option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.AnyDevice = true
 	}
 }
 
-// OnLJ subscribes to left joystick click events
-func (g *Gamepad) OnLJ(h buttonHandler, events ...ButtonEvent) {
-	g.ljBtn = &button{
-		handler: h,
-		events:  events,
+// WithStrictMapping makes NewGamepad and WatchMappingFile reject a mapping that fails ValidateMapping instead of only logging it
+func WithStrictMapping() option {
+	return func(gamepad *Gamepad) {
+		gamepad.strictMapping = true
 	}
 }
 
-// OnRJ subscribes to right joystick click events
-func (g *Gamepad) OnRJ(h buttonHandler, events ...ButtonEvent) {
-	g.rjBtn = &button{
-		handler: h,
-		events:  events,
+// WithRawButtonThreshold sets the fraction of full pressure, in 0..1, an analog button or trigger must reach to count as
+// pressed, for pressure sensitive buttons reported by a Decoder. Darwin only, see ConnectConfig.ButtonThreshold.
+func WithRawButtonThreshold(fraction float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.ButtonThreshold = fraction
 	}
 }
 
-// OnCross subscribes to X button events
-func (g *Gamepad) OnCross(h buttonHandler, events ...ButtonEvent) {
-	g.crossBtn = &button{
-		handler: h,
-		events:  events,
+// WithMinReportInterval delivers at most one report per axis every d, keeping the latest value, for slow boards with
+// controllers that report faster than they can keep up with. Button presses and releases are never dropped.
+// Only dispatch is thinned, which is where handlers and filters spend the time: every report is still read and, on
+// macOS, decoded, since a registered Decoder may rely on seeing every report.
+func WithMinReportInterval(d time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.MinReportInterval = d
 	}
 }
 
-// OnCircle subscribes to O button events
-func (g *Gamepad) OnCircle(h buttonHandler, events ...ButtonEvent) {
-	g.circleBtn = &button{
-		handler: h,
-		events:  events,
+// WithDeviceID connects to the device with this ID from Devices rather than the first gamepad found, see GamepadManager
+// for handling several
+func WithDeviceID(id string) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.DeviceID = id
 	}
 }
 
-// OnSquare subscribes to [] events
-func (g *Gamepad) OnSquare(h buttonHandler, events ...ButtonEvent) {
-	g.squareBtn = &button{
-		handler: h,
-		events:  events,
+// WithDevicePath reads joystick API events from path instead of a device in /dev/input, e.g. a FIFO written by a test harness.
+// The X-Box 360 mapping is used unless WithMapping is given. Linux only.
+func WithDevicePath(path string) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.DevicePath = path
 	}
 }
 
-// OnTriangle subscribes to /\ button events
-func (g *Gamepad) OnTriangle(h buttonHandler, events ...ButtonEvent) {
-	g.triangleBtn = &button{
-		handler: h,
-		events:  events,
+// WithBinaryLog writes the device's raw reports, the events decoded from them and what they were resolved as to w,
+// with timestamps, for tracking down input glitches. Read it back with DumpBinaryLog.
+func WithBinaryLog(w io.Writer) option {
+	return func(gamepad *Gamepad) {
+		gamepad.binaryLog = NewBinaryLog(w)
 	}
 }
 
-func (g *Gamepad) debugLn(s string) {
-	if g.debug {
-		log.Println(s)
+// WithRecording writes every device event to w, to be played back later WithReplay
+func WithRecording(w io.Writer) option {
+	return func(gamepad *Gamepad) {
+		gamepad.recorder = NewRecorder(w)
 	}
 }
 
-func (g *Gamepad) handleEvents() {
-	for {
-		select {
-		case event := <-g.device.OnButton():
-			var pos ButtonPosition
-			if event.Value <= 0 {
-				pos = UpPosition
-			} else {
-				pos = DownPosition
-			}
+// WithReplay plays back a recording instead of connecting to a device, with the recorded timing. With WithAutoReconnect
+// a recorded reconnect moves on to the next device of the recording, the gamepad stops once it has none.
+func WithReplay(r io.Reader) option {
+	return func(gamepad *Gamepad) {
+		gamepad.replay = r
+	}
+}
 
-			resolved, ok := g.inputMapping[Input{
-				Type:  InputTypeButton,
-				Value: event.Button,
-			}]
-			if !ok {
-				g.debugLn(fmt.Sprintf("Button unknown: %v\n", event.Button))
-				continue
-			}
+// WithReplaySpeed scales the playback of WithReplay, 2 plays twice as fast and 0.5 at half speed. It's clamped to 0.1..10.
+func WithReplaySpeed(factor float64) option {
+	return func(gamepad *Gamepad) {
+		gamepad.replayConfig.Speed = factor
+	}
+}
 
-			if g.debug {
-				g.debugLn(fmt.Sprintf("Button, input: %v, resolved as: %v\n", event.Button, resolved))
-			}
+// WithReplayStepping plays back WithReplay one event per call to Step, for frame by frame debugging
+func WithReplayStepping() option {
+	return func(gamepad *Gamepad) {
+		gamepad.replayConfig.Stepped = true
+	}
+}
 
-			switch resolved {
-			case CrossButton:
-				if err := g.processButton(g.crossBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case CircleButton:
-				if err := g.processButton(g.circleBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case SquareButton:
-				if err := g.processButton(g.squareBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case TriangleButton:
-				if err := g.processButton(g.triangleBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case L1Button:
-				if err := g.processButton(g.l1Btn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case R1Button:
-				if err := g.processButton(g.r1Btn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case SelectButton:
-				if err := g.processButton(g.selectBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case StartButton:
-				if err := g.processButton(g.startBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case AnalogButton:
-				if err := g.processButton(g.analogBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case LeftJoyButton:
-				if err := g.processButton(g.ljBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			case RightJoyButton:
-				if err := g.processButton(g.rjBtn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
-			default:
-				g.debugLn(fmt.Sprintf("Button event, button: %v, value: %v, when: %v\n", event.Button, event.Value, event.When))
+// WithReadTimeout raises ErrReadTimeout through OnError when the device sends nothing for d, telling a hung device apart from an idle one
+func WithReadTimeout(d time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.ReadTimeout = d
+	}
+}
 
-			}
+// WithDisableKernelRepeat turns off kernel autorepeat on Linux, so repeats only come from the library
+func WithDisableKernelRepeat() option {
+	return func(gamepad *Gamepad) {
+		gamepad.connectConfig.DisableKernelRepeat = true
+	}
+}
 
-		case event := <-g.device.OnAxis():
-			resolved, ok := g.inputMapping[Input{
-				Type:  InputTypeAxis,
-				Value: event.Axis,
-			}]
-			if !ok {
-				g.debugLn(fmt.Sprintf("Button unknown: %v\n", event.Axis))
-				continue
+// WithStickRotation rotates both joysticks by deg degrees (counter-clockwise), to correct for a non-standard mounting
+func WithStickRotation(deg float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.rotations[LeftJoyXAxis] = degToRad(deg)
+		gamepad.rotations[RightJoyXAxis] = degToRad(deg)
+	}
+}
+
+// WithLeftStickRotation rotates the left joystick by deg degrees (counter-clockwise)
+func WithLeftStickRotation(deg float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.rotations[LeftJoyXAxis] = degToRad(deg)
+	}
+}
+
+// WithRightStickRotation rotates the right joystick by deg degrees (counter-clockwise)
+func WithRightStickRotation(deg float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.rotations[RightJoyXAxis] = degToRad(deg)
+	}
+}
+
+func degToRad(deg float32) float64 {
+	return float64(deg) * math.Pi / 180
+}
+
+// WithPerAxisDeadzone zeroes x and y of a stick separately while each is within its own deadzone, for sticks where one axis is noisier.
+// Values outside are rescaled to start from zero at the deadzone edge. It applies before any radial check such as With8WayDeadzone.
+func WithPerAxisDeadzone(group AxisGroup, xDead, yDead float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.axisDeadzones[group] = [2]float64{float64(xDead), float64(yDead)}
+	}
+}
+
+// axisDeadzone zeroes v within deadzone d and rescales the remaining range back to 0..1
+func axisDeadzone(v, d float64) float64 {
+	if d <= 0 {
+		return v
+	}
+	if d >= 1 || math.Abs(v) <= d {
+		return 0
+	}
+	return math.Copysign((math.Abs(v)-d)/(1-d), v)
+}
+
+// WithOutputRange maps direction handler output from -1..1 onto min..max, e.g. 0..255.
+// The mapping happens last, after rotation and clamping, OnEverything and Subscribe still see -1..1.
+func WithOutputRange(min, max float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.outputRange = &[2]float64{float64(min), float64(max)}
+	}
+}
+
+// WithAxisClamp clamps normalized stick values to min..max instead of -1..1
+func WithAxisClamp(min, max float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.clamp = &[2]float64{float64(min), float64(max)}
+	}
+}
+
+// WithSpikeFilter holds back a stick sample that moves further than maxDeltaPerEvent from the previous one, in normalized
+// units of which the full travel is 2, as a likely corrupt report. A next sample of the same axis back near the previous
+// value drops it as a spike. Otherwise it's let through by the next sample near it, the next event of any other input, or
+// after 50ms, so a real flick arrives slightly late but a stick held at the end doesn't get stuck.
+// The dpad and triggers aren't filtered, they jump end to end by design.
+func WithSpikeFilter(maxDeltaPerEvent float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.spikeDelta = float64(maxDeltaPerEvent)
+		gamepad.spikeSuspects = make(map[Resolved]int)
+	}
+}
+
+// WithNoClamp leaves normalized stick values unclamped, so over-range readings from a miscalibrated stick are visible
+func WithNoClamp() option {
+	return func(gamepad *Gamepad) {
+		gamepad.clamp = nil
+	}
+}
+
+// WithNormalizedDPad scales dpad diagonals to a unit vector like a stick, so up and right reports about (0.707, 0.707) rather than (1, 1)
+func WithNormalizedDPad() option {
+	return func(gamepad *Gamepad) {
+		gamepad.normalizeDPad = true
+	}
+}
+
+// WithHandlerTimeout reports any handler running longer than d through OnError with ErrSlowHandler, naming the button and event.
+// Handlers run on the event loop, so a slow one delays every event behind it.
+func WithHandlerTimeout(d time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.handlerTimeout = d
+	}
+}
+
+// WithoutDuplicateFilter delivers every position the device reports for a button, including repeats of the current one.
+// Depending on the device this means repeated Down events while a button is held, e.g. from kernel autorepeat.
+// Click and hold detection are unaffected, only the Down and Up events repeat.
+func WithoutDuplicateFilter() option {
+	return func(gamepad *Gamepad) {
+		gamepad.noDupFilter = true
+	}
+}
+
+// WithTriggerSoftThreshold sets where a trigger enters stage 1 for OnL2Stage and OnR2Stage, in 0..1. The default is 0.5.
+func WithTriggerSoftThreshold(t float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.softThreshold = t
+	}
+}
+
+// WithTriggerHysteresis sets separate thresholds, in 0..1, for a trigger to press above and release at or below as a button.
+// A release below press keeps a trigger held partway from flickering between Down and Up. A release above press is
+// lowered to press.
+func WithTriggerHysteresis(press, release float32) option {
+	return func(gamepad *Gamepad) {
+		if release > press {
+			release = press
+		}
+		gamepad.triggerHysteresis = &[2]float32{press, release}
+	}
+}
+
+// faceRing is the face buttons in order around the cluster, counter-clockwise from the bottom
+var faceRing = [...]Resolved{CrossButton, CircleButton, TriangleButton, SquareButton}
+
+// WithFaceButtonRotation rotates the face buttons by steps around the cluster, with 1 Cross acts as Circle, Circle as Triangle
+// and so on. Negative steps go the other way. It applies on top of the mapping, to every handler and subscriber.
+func WithFaceButtonRotation(steps int) option {
+	return func(gamepad *Gamepad) {
+		gamepad.faceRotation = steps
+	}
+}
+
+// WithStickSnap pulls a stick to the nearest cardinal or diagonal direction when it's within toleranceDeg of it, keeping the
+// magnitude. It's an accessibility assist for holding a straight line, unlike OnLeftStick8Way the output stays continuous.
+func WithStickSnap(group AxisGroup, toleranceDeg float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.snaps[group] = degToRad(toleranceDeg)
+	}
+}
+
+// WithStickAlignment sets how far both sticks must be pushed, in 0..1, and how many degrees apart they may point
+// to count as aligned for OnBothSticksAligned. The defaults are 0.5 and 20 degrees.
+func WithStickAlignment(magnitude, toleranceDeg float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.alignMagnitude = float64(magnitude)
+		gamepad.alignTolerance = degToRad(toleranceDeg)
+	}
+}
+
+// WithRumbleCurve maps Rumble strengths before they are written, both ends in 0..1. The default is a 0.5 gamma so strength
+// feels linear, motors barely spin at low values otherwise. Pass func(v float32) float32 { return v } for the raw scale.
+func WithRumbleCurve(fn func(float32) float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.rumbleCurve = fn
+	}
+}
+
+// WithMouseAcceleration sets the response of OnRightJoystickMouse, speed is maxSpeed pixels a second times the stick
+// magnitude raised to exponent. The defaults are 2 and 1000, 1 gives a linear response.
+func WithMouseAcceleration(exponent, maxSpeed float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.mouseExponent = float64(exponent)
+		gamepad.mouseSpeed = float64(maxSpeed)
+	}
+}
+
+// WithTimestampSource selects what InputEvent.When is based on, DeviceTimestamps by default
+func WithTimestampSource(src TimestampSource) option {
+	return func(gamepad *Gamepad) {
+		gamepad.timestamps = src
+	}
+}
+
+// WithToggle makes a button latch, each click flips its state. See OnToggle and State.
+func WithToggle(button Resolved) option {
+	return func(gamepad *Gamepad) {
+		gamepad.toggles[button] = &toggle{}
+	}
+}
+
+// WithStickyHold makes a click on the button start a hold that stays active after release, until the next click. See OnStickyHold.
+func WithStickyHold(button Resolved) option {
+	return func(gamepad *Gamepad) {
+		gamepad.stickyHolds[button] = &stickyHold{}
+	}
+}
+
+// WithAnalogAsToggle treats the Analog button as the mode switch it is on older controllers, see AnalogMode
+func WithAnalogAsToggle() option {
+	return WithToggle(AnalogButton)
+}
+
+func (g *Gamepad) Close() error {
+	if g.gracePeriod > 0 {
+		g.drain()
+	}
+	g.cancel()
+
+	g.mu.Lock()
+	for _, t := range g.toggles {
+		t.on = false
+	}
+	for _, h := range g.stickyHolds {
+		h.active = false
+	}
+	for sub := range g.subscribers {
+		delete(g.subscribers, sub)
+		close(sub.ch)
+	}
+	g.mu.Unlock()
+	return nil
+}
+
+// drain stops reading input and waits, at most the grace period, for the event loop to dispatch what was already read
+func (g *Gamepad) drain() {
+	g.mu.Lock()
+	g.closing = true
+	device := g.device
+	g.mu.Unlock()
+
+	device.StopInput()
+	select {
+	case <-g.drained:
+	case <-time.After(g.gracePeriod):
+	}
+}
+
+// State returns a snapshot of the latched state
+func (g *Gamepad) State() State {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s := State{
+		Toggles:     make(map[Resolved]bool, len(g.toggles)),
+		StickyHolds: make(map[Resolved]bool, len(g.stickyHolds)),
+	}
+	for b, t := range g.toggles {
+		s.Toggles[b] = t.on
+	}
+	if t, ok := g.toggles[AnalogButton]; ok {
+		s.AnalogMode = t.on
+	}
+	for b, h := range g.stickyHolds {
+		s.StickyHolds[b] = h.active
+	}
+	return s
+}
+
+// AnalogMode reports whether analog mode is latched on, always false without WithAnalogAsToggle
+func (g *Gamepad) AnalogMode() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	t, ok := g.toggles[AnalogButton]
+	return ok && t.on
+}
+
+// OnStickyHold subscribes to a sticky hold starting and ending, the button is made sticky if WithStickyHold was not used.
+// A hold starts on a click of the button and ends on the next one, a press long enough to hold doesn't click and leaves
+// it as it is.
+func (g *Gamepad) OnStickyHold(button Resolved, h stickyHoldHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s, ok := g.stickyHolds[button]
+	if !ok {
+		s = &stickyHold{}
+		g.stickyHolds[button] = s
+	}
+	s.handler = h
+}
+
+// PressCounts returns how many clicks and holds each button has produced since start or the last ResetCounts
+func (g *Gamepad) PressCounts() map[Resolved]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	counts := make(map[Resolved]int, len(g.pressCounts))
+	for b, n := range g.pressCounts {
+		counts[b] = n
+	}
+	return counts
+}
+
+// ResetCounts zeroes the press counters
+func (g *Gamepad) ResetCounts() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pressCounts = make(map[Resolved]int)
+}
+
+// OnToggle subscribes to the latched state of a toggle button, the button is made a toggle if WithToggle was not used
+func (g *Gamepad) OnToggle(button Resolved, h toggleHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	t, ok := g.toggles[button]
+	if !ok {
+		t = &toggle{}
+		g.toggles[button] = t
+	}
+	t.handler = h
+}
+
+// OnError subscribes to errors raised by the device
+func (g *Gamepad) OnError(h errorHandler) {
+	g.errorHandler = h
+}
+
+// OnConnectionChange subscribes to the device disconnecting and, with WithAutoReconnect, connecting again.
+// A disconnect carries a best effort reason, e.g. DisconnectRemoved for a controller turned off and DisconnectIOError
+// for a flaky cable. Connecting carries NotDisconnected.
+func (g *Gamepad) OnConnectionChange(h connectionHandler) {
+	g.connHandler = h
+}
+
+// OnEverything subscribes to every button and axis event the gamepad produces
+func (g *Gamepad) OnEverything(h inputEventHandler) {
+	g.everything = h
+}
+
+// OnAxisChange subscribes to every analog control in one place, triggers report their 0..1 value as x
+func (g *Gamepad) OnAxisChange(h axisChangeHandler) {
+	g.axisHandler = h
+}
+
+// OnButtonsChanged subscribes to changes of the set of pressed buttons, with the buttons pressed and released since the last call.
+// It's called before the button's own DownEvent or UpEvent.
+func (g *Gamepad) OnButtonsChanged(h buttonsChangedHandler) {
+	g.changedHandler = h
+}
+
+// OnL2Axis subscribes to the analog value of L2 in 0..1. It can be used together with OnL2, every trigger event feeds both.
+func (g *Gamepad) OnL2Axis(h triggerHandler) {
+	g.l2AxisHandler = h
+}
+
+// OnR2Axis subscribes to the analog value of R2 in 0..1, see OnL2Axis
+func (g *Gamepad) OnR2Axis(h triggerHandler) {
+	g.r2AxisHandler = h
+}
+
+// OnPedals subscribes to the triggers combined into one pedal axis, R2 (gas) minus L2 (brake) in -1..1
+func (g *Gamepad) OnPedals(h pedalHandler) {
+	g.pedalHandler = h
+}
+
+// OnL2Stage subscribes to L2 as a two stage trigger, stage is 0 released, 1 past the soft threshold or 2 fully pressed.
+// Called only when the stage changes, see WithTriggerSoftThreshold.
+func (g *Gamepad) OnL2Stage(h triggerStageHandler) {
+	g.l2StageHandler = h
+}
+
+// OnR2Stage subscribes to R2 as a two stage trigger, see OnL2Stage
+func (g *Gamepad) OnR2Stage(h triggerStageHandler) {
+	g.r2StageHandler = h
+}
+
+// OnDPad subscribes to dpad events
+func (g *Gamepad) OnDPad(h directionHandler) {
+	g.dpadHandler = h
+}
+
+// OnLeftJoystick subscribes to left joystick move events
+func (g *Gamepad) OnLeftJoystick(h directionHandler) {
+	g.leftJoyHandler = h
+}
+
+// OnRightJoystick subscribes to right joystick move events
+func (g *Gamepad) OnRightJoystick(h directionHandler) {
+	g.rightJoyHandler = h
+}
+
+// OnDPad64 subscribes to dpad events with float64 precision
+func (g *Gamepad) OnDPad64(h directionHandler64) {
+	g.dpadHandler64 = h
+}
+
+// OnLeftJoystick64 subscribes to left joystick move events with float64 precision
+func (g *Gamepad) OnLeftJoystick64(h directionHandler64) {
+	g.leftJoyHandler64 = h
+}
+
+// OnRightJoystick64 subscribes to right joystick move events with float64 precision
+func (g *Gamepad) OnRightJoystick64(h directionHandler64) {
+	g.rightJoyHandler64 = h
+}
+
+// OnLeftStick8Way subscribes to the left joystick snapped to 8 directions, called only when the direction changes.
+// Directions are physical, North is the stick pushed up regardless of WithInvertedY.
+func (g *Gamepad) OnLeftStick8Way(h direction8Handler) {
+	g.leftJoy8WayHandler = h
+}
+
+// OnLeftStickTouch subscribes to whether the left stick is off center before the deadzone is applied, called only when it changes.
+// It tells a hand resting within the deadzone apart from a stick that's let go, the device's noise floor still counts as centered.
+func (g *Gamepad) OnLeftStickTouch(h stickTouchHandler) {
+	g.leftTouchHandler = h
+}
+
+// OnLeftJoystickDelta subscribes to the change in left joystick position since the previous event, e.g. for mouse emulation.
+// Values go through the same processing as OnLeftJoystick. Returning to center resets the position without reporting a delta.
+func (g *Gamepad) OnLeftJoystickDelta(h directionHandler) {
+	g.leftJoyDeltaHandler = h
+}
+
+// OnBothSticksAligned subscribes to both sticks being pushed the same way, called once when alignment begins with the shared
+// direction and the weaker stick's magnitude, and again with Neutral and zero when it breaks. See WithStickAlignment.
+func (g *Gamepad) OnBothSticksAligned(h alignedHandler) {
+	g.alignedHandler = h
+}
+
+// OnTouchpadMove subscribes to the touchpad finger position, normalized to 0..1 across the pad, for pointer style interaction.
+// A lifted finger reports touching false with the last position. This is separate from any touchpad click button.
+// No built-in mapping or decoder reports TouchpadXAxis and TouchpadYAxis, so nothing is delivered until a custom mapping
+// or a Decoder registered on macOS does, DS4Touch and DualSenseTouch decode the touchpad of their reports. On Linux the DS4 and DualSense touchpad is a separate evdev device, which the
+// joystick API this library reads doesn't cover.
+// It returns ErrUnsupported when the current mapping has no touchpad axes. The handler is kept either way, for a mapping
+// loaded later that has them.
+func (g *Gamepad) OnTouchpadMove(h touchpadHandler) error {
+	g.touchpadHandler = h
+	if !g.mapsTo(TouchpadXAxis) && !g.mapsTo(TouchpadYAxis) {
+		return fmt.Errorf("touchpad: %w", ErrUnsupported)
+	}
+	return nil
+}
+
+// OnL1 subscribes to L1 button events
+func (g *Gamepad) OnL1(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(L1Button, h, events)
+}
+
+// OnR1 subscribes to R1 button events
+func (g *Gamepad) OnR1(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(R1Button, h, events)
+}
+
+// OnL2 subscribes to L2 button events
+func (g *Gamepad) OnL2(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(L2Axis, h, events)
+}
+
+// OnR2 subscribes to R2 button events
+func (g *Gamepad) OnR2(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(R2Axis, h, events)
+}
+
+// OnSelect subscribes to select button events
+func (g *Gamepad) OnSelect(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(SelectButton, h, events)
+}
+
+// OnStart subscribes to start button events
+func (g *Gamepad) OnStart(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(StartButton, h, events)
+}
+
+// OnAnalog subscribes to analog button events. On controllers without one, such as the 360 pad, the Guide button is also
+// delivered here and to WithAnalogAsToggle, but subscribers and OnEverything only see it as GuideButton.
+func (g *Gamepad) OnAnalog(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(AnalogButton, h, events)
+}
+
+// OnLJ subscribes to left joystick click events
+func (g *Gamepad) OnLJ(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(LeftJoyButton, h, events)
+}
+
+// OnRJ subscribes to right joystick click events
+func (g *Gamepad) OnRJ(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(RightJoyButton, h, events)
+}
+
+// OnGuide subscribes to guide/home button events
+func (g *Gamepad) OnGuide(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(GuideButton, h, events)
+}
+
+// OnDPadUp subscribes to dpad up events, treating the direction as a button
+func (g *Gamepad) OnDPadUp(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(DPadUpButton, h, events)
+}
+
+// OnDPadDown subscribes to dpad down events, treating the direction as a button
+func (g *Gamepad) OnDPadDown(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(DPadDownButton, h, events)
+}
+
+// OnDPadLeft subscribes to dpad left events, treating the direction as a button
+func (g *Gamepad) OnDPadLeft(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(DPadLeftButton, h, events)
+}
+
+// OnDPadRight subscribes to dpad right events, treating the direction as a button
+func (g *Gamepad) OnDPadRight(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(DPadRightButton, h, events)
+}
+
+// OnCross subscribes to X button events
+func (g *Gamepad) OnCross(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(CrossButton, h, events)
+}
+
+// OnCircle subscribes to O button events
+func (g *Gamepad) OnCircle(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(CircleButton, h, events)
+}
+
+// OnSquare subscribes to [] events
+func (g *Gamepad) OnSquare(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(SquareButton, h, events)
+}
+
+// OnTriangle subscribes to /\ button events
+func (g *Gamepad) OnTriangle(h buttonHandler, events ...ButtonEvent) {
+	g.setButton(TriangleButton, h, events)
+}
+
+// OnHoldRelease subscribes to a button being released after being held at least the hold duration, with how long it was held.
+// Useful for charge mechanics, it's more precise than combining Hold and Up events.
+func (g *Gamepad) OnHoldRelease(b Resolved, h holdReleaseHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holdReleases[b] = h
+}
+
+// OnCrossHoldRelease subscribes to X being released after a hold, see OnHoldRelease
+func (g *Gamepad) OnCrossHoldRelease(h holdReleaseHandler) {
+	g.OnHoldRelease(CrossButton, h)
+}
+
+// OnHoldTiers subscribes to a button held for increasingly long, each tier's handler is called once its duration is reached
+// during the same press, e.g. 500ms, 2s and 4s for more and more. Releasing cancels the tiers not reached yet.
+func (g *Gamepad) OnHoldTiers(b Resolved, tiers []HoldTier) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.holdTiers[b] = tiers
+}
+
+// OnButtonWithModifier subscribes to events of target that occur while modifier is down, like a shift key.
+// When it fires it takes the place of the plain handler of target for that event.
+func (g *Gamepad) OnButtonWithModifier(target, modifier Resolved, h buttonHandler, events ...ButtonEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.modifiers[target] = append(g.modifiers[target], modifierBinding{
+		modifier: modifier,
+		button: button{
+			handler: h,
+			events:  events,
+		},
+	})
+}
+
+// OnButtonRaw subscribes to every position reported for a button, bypassing click/hold detection and duplicate swallowing
+func (g *Gamepad) OnButtonRaw(b Resolved, h rawButtonHandler) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rawHandlers[b] = h
+}
+
+// connect opens a device and loads the mapping for whichever driver it reports
+func (g *Gamepad) connect() error {
+	ctx, cancel := context.WithCancel(g.ctx)
+
+	var device *HID
+	var err error
+	if g.replay != nil {
+		device, err = Replay(ctx, g.replay, g.replayConfig)
+	} else {
+		device, err = Connect(ctx, g.connectConfig)
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	if g.recorder != nil {
+		device.Record(g.recorder)
+	}
+	if g.binaryLog != nil {
+		device.LogTo(g.binaryLog)
+	}
+
+	g.mu.Lock()
+	if g.device != nil {
+		buttons, axes := g.device.DroppedEvents()
+		g.dropped[0] += buttons
+		g.dropped[1] += axes
+	}
+	g.device = device
+	g.deviceCancel = cancel
+	g.deviceStart = time.Now()
+	// Each gamepad works on its own copy, so gamepads sharing a driver don't share its mapping
+	g.inputMapping = DriverMapping[device.Driver].Copy()
+	if g.customMapping != nil {
+		g.inputMapping = g.customMapping.Copy()
+	}
+	g.axisMapping = DriverAxisMapping[device.Driver].Copy()
+	if g.customMapping != nil || g.customAxes != nil {
+		g.axisMapping = g.customAxes.Copy()
+	}
+	g.mu.Unlock()
+	g.loadAxisNoise()
+	g.checkMapping()
+	return nil
+}
+
+// loadAxisNoise takes the fuzz the device reports for each axis as a built-in deadzone
+func (g *Gamepad) loadAxisNoise() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.axisNoise = make(map[Resolved]float64)
+	for i, r := range g.device.AxisRanges() {
+		resolved, ok := g.inputMapping[Input{Type: InputTypeAxis, Value: uint8(i)}]
+		if !ok || r.Fuzz <= 0 || r.Max <= r.Min {
+			continue
+		}
+		g.axisNoise[resolved] = float64(r.Fuzz) / (float64(r.Max-r.Min) / 2)
+	}
+}
+
+// connectWithRetry makes the first connection, retrying as configured by WithConnectRetry
+func (g *Gamepad) connectWithRetry() error {
+	err := g.connect()
+	for i := 0; err != nil && i < g.connectAttempts; i++ {
+		g.debugLn(fmt.Sprintf("Connect failed, retrying: %v\n", err))
+		select {
+		case <-g.ctx.Done():
+			return g.ctx.Err()
+		case <-time.After(g.connectDelay):
+		}
+		err = g.connect()
+	}
+	return err
+}
+
+// handleDisconnect releases the lost device and, when enabled, waits for a new one. It reports whether events can keep flowing.
+func (g *Gamepad) handleDisconnect() bool {
+	g.deviceCancel()
+	g.releaseAll()
+	reason := g.device.DisconnectReason()
+	g.debugLn(fmt.Sprintf("Device disconnected, driver: %v, reason: %v\n", g.device.Driver, reason))
+	if g.connHandler != nil {
+		g.connHandler(false, string(g.device.Driver), reason)
+	}
+	if g.lifecycle != nil {
+		g.lifecycle(LifecycleEvent{Kind: DeviceDisconnected, Gamepad: g, Reason: reason})
+	}
+	return g.awaitDevice()
+}
+
+// deviceLost tells a GamepadManager the event loop stopped for want of a device
+func (g *Gamepad) deviceLost() {
+	if g.lost != nil && g.ctx.Err() == nil {
+		g.lost()
+	}
+}
+
+// awaitDevice looks for a new device every reconnect interval when WithAutoReconnect is set, reporting whether one connected
+func (g *Gamepad) awaitDevice() bool {
+	if g.reconnect <= 0 {
+		return false
+	}
+
+	for {
+		select {
+		case <-g.ctx.Done():
+			return false
+		case <-time.After(g.reconnect):
+		}
+
+		if err := g.connect(); err != nil {
+			g.debugLn(fmt.Sprintf("Reconnect failed: %v\n", err))
+			// A replay isn't retried, its recording has no next device to connect to
+			if g.replay != nil {
+				return false
 			}
+			continue
+		}
+		g.connected()
+		return true
+	}
+}
 
-			g.debugLn(fmt.Sprintf("Axis, input: %v, resolved as: %v\n", event.Axis, resolved))
+// Reconnect drops the current device and connects to whichever controller is found now, e.g. after swapping controllers.
+// Handlers and options carry over, the mapping is looked up again for the new device. It's carried out by the event loop
+// and waits for it. When no controller is found the error is returned and, with WithAutoReconnect, the gamepad keeps
+// looking as after a disconnect, otherwise it stops delivering events.
+func (g *Gamepad) Reconnect() error {
+	done := make(chan error, 1)
+	select {
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	case g.reconnectCh <- done:
+	}
+	return <-done
+}
 
-			g.axisCache[resolved] = int(event.Value)
+// reconnectNow swaps the device for Reconnect, reporting whether events can keep flowing
+func (g *Gamepad) reconnectNow(done chan<- error) bool {
+	g.deviceCancel()
+	g.releaseAll()
+	g.debugLn(fmt.Sprintf("Device released for reconnect, driver: %v\n", g.device.Driver))
+	if g.connHandler != nil {
+		g.connHandler(false, string(g.device.Driver), DisconnectEnded)
+	}
+	if g.lifecycle != nil {
+		g.lifecycle(LifecycleEvent{Kind: DeviceDisconnected, Gamepad: g, Reason: DisconnectEnded})
+	}
 
-			if resolved == DPadXAxis || resolved == DPadYAxis {
-				if err := g.emitDirection(g.dpadHandler, DPadXAxis, DPadYAxis); err != nil {
-					g.debugLn(err.Error())
-				}
-				continue
+	err := g.connect()
+	done <- err
+	if err != nil {
+		return g.awaitDevice()
+	}
+	g.connected()
+	return true
+}
+
+// connected resets the state left by the previous device and reports the new one
+func (g *Gamepad) connected() {
+	g.mu.Lock()
+	for i := range g.axisCache {
+		g.axisCache[i] = 0
+	}
+	g.axisCache[L2Axis] = triggerRest
+	g.axisCache[R2Axis] = triggerRest
+	g.mu.Unlock()
+	for i := range g.triggerPositions {
+		g.triggerPositions[i] = UpPosition
+	}
+	for i := range g.triggerStages {
+		delete(g.triggerStages, i)
+	}
+	g.leftTouching = false
+	for i := range g.spikeSuspects {
+		delete(g.spikeSuspects, i)
+	}
+	g.spikeRelease = nil
+	for i := range g.drifts {
+		delete(g.drifts, i)
+	}
+
+	g.debugLn(fmt.Sprintf("Device connected, driver: %v\n", g.device.Driver))
+	if g.connHandler != nil {
+		g.connHandler(true, string(g.device.Driver), NotDisconnected)
+	}
+	if g.lifecycle != nil {
+		g.lifecycle(LifecycleEvent{Kind: DeviceConnected, Gamepad: g})
+	}
+}
+
+// releaseAll delivers Up for every button still down, so a lost device never leaves a button stuck
+func (g *Gamepad) releaseAll() {
+	for resolved := range g.inputsDown {
+		delete(g.inputsDown, resolved)
+	}
+	for resolved := range g.buttonStates {
+		if g.IsPressed(resolved) {
+			if err := g.processButton(resolved, UpPosition); err != nil {
+				g.debugLn(err.Error())
 			}
+		}
+	}
+}
 
-			if resolved == LeftJoyXAxis || resolved == LeftJoyYAxis {
-				if err := g.emitDirection(g.leftJoyHandler, LeftJoyXAxis, LeftJoyYAxis); err != nil {
-					g.debugLn(err.Error())
-				}
-				continue
+// logResolved writes what an input resolved as to the binary log, if any
+func (g *Gamepad) logResolved(in Input, resolved Resolved, ok bool, value int) {
+	if g.binaryLog == nil {
+		return
+	}
+	if !ok {
+		resolved = Unresolved
+	}
+	g.binaryLog.Resolved(in, resolved, value)
+}
+
+// spike reports whether a stick sample should be held back by WithSpikeFilter, confirming a held back sample it's close to
+func (g *Gamepad) spike(r Resolved, value int) bool {
+	if g.spikeSuspects == nil || !isStickAxis(r) {
+		return false
+	}
+
+	near := func(from int) bool {
+		return math.Abs(float64(value-from))/MaxValue <= g.spikeDelta
+	}
+
+	suspect, held := g.spikeSuspects[r]
+	delete(g.spikeSuspects, r)
+	g.mu.Lock()
+	prev := g.axisCache[r]
+	g.mu.Unlock()
+	if near(prev) || (held && near(suspect)) {
+		return false
+	}
+
+	g.spikeSuspects[r] = value
+	if g.spikeRelease == nil {
+		g.spikeRelease = time.After(spikeHoldTime)
+	}
+	g.debugLn(fmt.Sprintf("Axis spike held back, axis: %v, from: %v, to: %v\n", r, prev, value))
+	return true
+}
+
+// releaseSpikes lets through the stick samples held back by WithSpikeFilter, except for the axis whose sample is being handled
+func (g *Gamepad) releaseSpikes(except Resolved) {
+	for r, value := range g.spikeSuspects {
+		if r == except {
+			continue
+		}
+		delete(g.spikeSuspects, r)
+		g.setAxis(r, value)
+		g.emitStick(r)
+	}
+	if len(g.spikeSuspects) == 0 {
+		g.spikeRelease = nil
+	}
+}
+
+func isStickAxis(r Resolved) bool {
+	switch r {
+	case LeftJoyXAxis, LeftJoyYAxis, RightJoyXAxis, RightJoyYAxis:
+		return true
+	}
+	return false
+}
+
+// emitStick delivers the stick an axis belongs to
+func (g *Gamepad) emitStick(r Resolved) {
+	switch r {
+	case LeftJoyXAxis, LeftJoyYAxis:
+		if err := g.emitDirection(LeftStickGroup, g.leftJoyHandler, g.leftJoyHandler64, LeftJoyXAxis, LeftJoyYAxis); err != nil {
+			g.debugLn(err.Error())
+		}
+	case RightJoyXAxis, RightJoyYAxis:
+		if err := g.emitDirection(RightStickGroup, g.rightJoyHandler, g.rightJoyHandler64, RightJoyXAxis, RightJoyYAxis); err != nil {
+			if g.debug {
+				g.debugLn(err.Error())
 			}
+		}
+	}
+	g.checkAlignment()
+}
 
-			if resolved == RightJoyXAxis || resolved == RightJoyYAxis {
-				if err := g.emitDirection(g.rightJoyHandler, RightJoyXAxis, RightJoyYAxis); err != nil {
-					if g.debug {
-						g.debugLn(err.Error())
-					}
+// setAxis updates the axis cache. Only the event loop writes it, taking the lock keeps AxisSnapshot safe while the loop
+// reads it without.
+func (g *Gamepad) setAxis(r Resolved, value int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.axisCache[r] = value
+}
+
+// AxisSnapshot returns a copy of the raw value of every axis, triggers included, as last reported by the device
+// and adjusted by any DriverAxisMapping.
+// It's meant for debugging, e.g. a live view of the values while working out a mapping for a new controller.
+func (g *Gamepad) AxisSnapshot() map[Resolved]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	s := make(map[Resolved]int, len(g.axisCache))
+	for r, v := range g.axisCache {
+		s[r] = v
+	}
+	return s
+}
+
+// mapped resolves an input through the active mapping, which WatchMappingFile may swap at any time
+func (g *Gamepad) mapped(in Input) (Resolved, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	resolved, ok := g.inputMapping[in]
+	if ok && g.faceRotation != 0 {
+		resolved = rotateFace(resolved, g.faceRotation)
+	}
+	return resolved, ok
+}
+
+// axisConfig looks up the scaling of an axis input, the axis mapping is replaced on connect and by WatchMappingFile
+func (g *Gamepad) axisConfig(in Input) (AxisConfig, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	cfg, ok := g.axisMapping[in]
+	return cfg, ok
+}
+
+// rotateFace moves a face button steps around the cluster, other buttons are returned as they are
+func rotateFace(r Resolved, steps int) Resolved {
+	for i, b := range faceRing {
+		if b == r {
+			n := len(faceRing)
+			return faceRing[((i+steps)%n+n)%n]
+		}
+	}
+	return r
+}
+
+// checkMapping warns about mapping entries referencing inputs the device doesn't have
+func (g *Gamepad) checkMapping() {
+	g.mu.Lock()
+	m := g.inputMapping
+	g.mu.Unlock()
+
+	if err := ValidateMapping(m); err != nil {
+		log.Printf("Mapping is ambiguous, err: %v", err)
+	}
+
+	buttons, axes := g.device.ButtonCount(), g.device.AxisCount()
+	for in, resolved := range m {
+		switch {
+		case in.Type == InputTypeButton && buttons > 0 && int(in.Value) >= buttons:
+			log.Printf("Mapping references button %v for %v but the device has %v buttons", in.Value, resolved, buttons)
+		case in.Type == InputTypeAxis && axes > 0 && int(in.Value) >= axes:
+			log.Printf("Mapping references axis %v for %v but the device has %v axes", in.Value, resolved, axes)
+		}
+	}
+}
+
+// WithTemporaryHandler binds h to a button until ctx is done, then restores the previous binding.
+// The previous binding is only restored if the button wasn't rebound in the meantime.
+func (g *Gamepad) WithTemporaryHandler(ctx context.Context, b Resolved, h buttonHandler, events ...ButtonEvent) {
+	ref := g.buttonRef(b)
+	if ref == nil {
+		return
+	}
+
+	temp := &button{
+		handler: h,
+		events:  events,
+	}
+
+	g.mu.Lock()
+	previous := *ref
+	*ref = temp
+	g.mu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-g.ctx.Done():
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if *ref == temp {
+			*ref = previous
+		}
+	}()
+}
+
+// MapButtonsToAxis emulates an analog axis with two digital buttons. While pos or neg is held the value
+// ramps toward 1 or -1 at rampRate per second, and back toward 0 once released. The value is emitted as x.
+func (g *Gamepad) MapButtonsToAxis(neg, pos Resolved, rampRate float32, h directionHandler) {
+	go func() {
+		ticker := time.NewTicker(axisRampInterval)
+		defer ticker.Stop()
+
+		var value float32
+		last := time.Now()
+		for {
+			var now time.Time
+			select {
+			case <-g.ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+
+			var target float32
+			if g.IsPressed(pos) {
+				target++
+			}
+			if g.IsPressed(neg) {
+				target--
+			}
+
+			step := rampRate * float32(now.Sub(last).Seconds())
+			last = now
+
+			next := value
+			switch {
+			case value < target:
+				next = value + step
+				if next > target {
+					next = target
+				}
+			case value > target:
+				next = value - step
+				if next < target {
+					next = target
 				}
+			}
+
+			if next != value {
+				value = next
+				h(value, 0)
+			}
+		}
+	}()
+}
+
+// OnRightJoystickMouse moves a mouse with the right stick, h gets whole pixel deltas every tick while the stick is off center.
+// Speed follows the stick magnitude raised to the acceleration exponent, so small pushes move slowly, see WithMouseAcceleration.
+// dy is in screen direction, positive down.
+func (g *Gamepad) OnRightJoystickMouse(h func(dx, dy int)) {
+	go func() {
+		ticker := time.NewTicker(axisRampInterval)
+		defer ticker.Stop()
+
+		var restX, restY float64
+		last := time.Now()
+		for {
+			var now time.Time
+			select {
+			case <-g.ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+
+			g.mu.Lock()
+			x, y := g.rightStick[0], -g.rightStick[1]*YAxisUp
+			paused := g.paused
+			g.mu.Unlock()
+
+			elapsed := now.Sub(last).Seconds()
+			last = now
+
+			m := math.Min(math.Hypot(x, y), 1)
+			if m == 0 || paused {
+				restX, restY = 0, 0
 				continue
 			}
+			speed := math.Pow(m, g.mouseExponent) * g.mouseSpeed * elapsed / m
+
+			// Carry the fraction so slow movement still adds up to whole pixels
+			restX += x * speed
+			restY += y * speed
+			dx, dy := int(restX), int(restY)
+			restX -= float64(dx)
+			restY -= float64(dy)
+			if dx != 0 || dy != 0 {
+				h(dx, dy)
+			}
+		}
+	}()
+}
+
+// DroppedEvents is the number of button and axis events dropped because the event loop fell behind, across reconnects.
+// Events dropped for a slow Subscribe channel are counted by DroppedSubscriberEvents.
+func (g *Gamepad) DroppedEvents() (buttons, axes uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	buttons, axes = g.device.DroppedEvents()
+	return g.dropped[0] + buttons, g.dropped[1] + axes
+}
+
+// IdleDuration is how long it has been since the last button or axis event
+func (g *Gamepad) IdleDuration() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Since(g.lastActivity)
+}
+
+func (g *Gamepad) touch(when time.Duration) {
+	g.mu.Lock()
+	g.lastActivity = time.Now()
+	g.lastWhen = g.deviceStart.Add(when)
+	g.mu.Unlock()
+}
+
+// stamp is the time of an event being published, the caller holds g.mu. An event synthesized later than the last device
+// event, e.g. a hold, is offset by the time since that event arrived.
+func (g *Gamepad) stamp() time.Time {
+	if g.timestamps == ClockTimestamps || g.lastWhen.IsZero() {
+		return time.Now()
+	}
+	return g.lastWhen.Add(time.Since(g.lastActivity))
+}
+
+// IsPressed reports whether a button is currently down, whether or not a handler is subscribed to it.
+// The triggers are reported through L2Axis and R2Axis.
+func (g *Gamepad) IsPressed(b Resolved) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	state, ok := g.buttonStates[b]
+	return ok && state.lastPosition == DownPosition
+}
+
+// Step advances a replay created WithReplayStepping by one event, reporting false once the recording has ended
+func (g *Gamepad) Step() bool {
+	g.mu.Lock()
+	device := g.device
+	g.mu.Unlock()
+	return device.Step()
+}
+
+// Rumble drives the strong (low frequency) and weak (high frequency) motors, each 0..1 and clamped, zero stops them.
+// Strengths go through the rumble curve, see WithRumbleCurve, then onto the hardware range:
+// 0..65535 FF_RUMBLE magnitudes on Linux, the top byte of those in the Xbox 360 output report on Darwin.
+func (g *Gamepad) Rumble(strong, weak float32) error {
+	if !g.device.HasRumble() {
+		return ErrUnsupported
+	}
+	return g.device.SetRumble(g.rumbleValue(strong), g.rumbleValue(weak))
+}
+
+func (g *Gamepad) rumbleValue(v float32) uint16 {
+	v = clamp01(v)
+	if g.rumbleCurve != nil {
+		v = clamp01(g.rumbleCurve(v))
+	} else {
+		v = float32(math.Pow(float64(v), defaultRumbleGamma))
+	}
+	return uint16(v * math.MaxUint16)
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 || v != v {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// SetPlayerIndicator shows player number n, 1..4, on the controller's player LEDs such as the Xbox 360 ring of light.
+// It returns ErrUnsupported for other numbers and controllers without player LEDs.
+func (g *Gamepad) SetPlayerIndicator(n int) error {
+	return g.device.SetPlayerLED(n)
+}
+
+// HasRumble reports whether the connected device supports rumble, probed from the device rather than its driver name
+func (g *Gamepad) HasRumble() bool {
+	return g.device.HasRumble()
+}
+
+// PressedButtons returns every button currently down in Resolved order, the triggers are reported as L2Axis and R2Axis
+func (g *Gamepad) PressedButtons() []Resolved {
+	standIn := g.guideStandsIn(AnalogButton)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	var pressed []Resolved
+	for b, state := range g.buttonStates {
+		if state.lastPosition == DownPosition && !(standIn && b == AnalogButton) {
+			pressed = append(pressed, b)
+		}
+	}
+	sort.Slice(pressed, func(i, j int) bool { return pressed[i] < pressed[j] })
+	return pressed
+}
+
+func (g *Gamepad) debugLn(s string) {
+	if g.debug {
+		log.Println(s)
+	}
+}
+
+// Run dispatches events to the handlers, blocking until ctx is cancelled or the gamepad is closed.
+// Only call it when the gamepad was created WithManualStart.
+func (g *Gamepad) Run(ctx context.Context) {
+	g.handleEvents(ctx)
+}
+
+// RawButtonChannel is the current device's unmapped button channel, for custom select loops. The gamepad's own dispatch reads
+// the same channel and each event goes to one reader, so use WithManualStart and don't call Run to have it to yourself.
+// A reconnect brings a new device with a new channel.
+func (g *Gamepad) RawButtonChannel() <-chan RawButtonEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.device.OnButton()
+}
+
+// RawAxisChannel is the current device's unmapped axis channel, see RawButtonChannel
+func (g *Gamepad) RawAxisChannel() <-chan RawAxisEvent {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.device.OnAxis()
+}
+
+func (g *Gamepad) handleEvents(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.ctx.Done():
+			return
+
+		case <-g.device.Disconnected():
+			g.mu.Lock()
+			closing := g.closing
+			g.mu.Unlock()
+			if closing {
+				close(g.drained)
+				return
+			}
+			if !g.handleDisconnect() {
+				g.deviceLost()
+				return
+			}
+
+		case event := <-g.device.OnButton():
+			g.touch(event.When)
+			g.releaseSpikes(-1)
 
-			// L2 and R2 are axis but we want them as buttons
 			var pos ButtonPosition
 			if event.Value <= 0 {
 				pos = UpPosition
 			} else {
 				pos = DownPosition
 			}
+			g.captureInput(Input{Type: InputTypeButton, Value: event.Button}, int(event.Value))
+
+			resolved, ok := g.mapped(Input{
+				Type:  InputTypeButton,
+				Value: event.Button,
+			})
+			g.logResolved(Input{Type: InputTypeButton, Value: event.Button}, resolved, ok, int(event.Value))
+			if !ok {
+				g.debugLn(fmt.Sprintf("Button unknown: %v\n", event.Button))
+				continue
+			}
+
+			if g.debug {
+				g.debugLn(fmt.Sprintf("Button, input: %v, resolved as: %v\n", event.Button, resolved))
+			}
 
-			if resolved == L2Axis {
-				if err := g.processButton(g.l2Btn, pos); err != nil {
+			switch resolved {
+			case CrossButton, CircleButton, SquareButton, TriangleButton,
+				L1Button, R1Button, SelectButton, StartButton, AnalogButton,
+				LeftJoyButton, RightJoyButton, GuideButton,
+				DPadUpButton, DPadDownButton, DPadLeftButton, DPadRightButton:
+				// Raw handlers see each input's own edges, the rest the coalesced state of every input mapped to the button
+				g.emitRaw(resolved, pos)
+				pos = g.coalesce(resolved, Input{Type: InputTypeButton, Value: event.Button}, pos)
+				// The direction goes first, as it does for a hat
+				if resolved >= DPadUpButton && resolved <= DPadRightButton {
+					g.emitDPadAxes()
+				}
+				g.repeatButton(resolved, pos)
+				if err := g.processButton(resolved, pos); err != nil {
+					g.debugLn(err.Error())
+				}
+				if resolved == GuideButton && !g.mapsTo(AnalogButton) {
+					// The 360 pad has no Analog button, its Guide button keeps serving OnAnalog and WithAnalogAsToggle
+					if err := g.processButton(AnalogButton, pos); err != nil {
+						g.debugLn(err.Error())
+					}
+				}
+			case L2Axis, R2Axis:
+				// Digital triggers, reported as fully pressed or released so the analog consumers still see them
+				value := triggerRest
+				if pos == DownPosition {
+					value = MaxValue
+				}
+				g.setAxis(resolved, value)
+				g.triggerPositions[resolved] = pos
+				g.handleTrigger(resolved, value, pos)
+			default:
+				g.debugLn(fmt.Sprintf("Button event, button: %v, value: %v, when: %v\n", event.Button, event.Value, event.When))
+
+			}
+
+		case event := <-g.device.OnAxis():
+			g.touch(event.When)
+
+			input := Input{
+				Type:  InputTypeAxis,
+				Value: event.Axis,
+			}
+			value := int(event.Value)
+			g.captureInput(input, value)
+
+			resolved, ok := g.mapped(input)
+			if cfg, found := g.axisConfig(input); found {
+				resolved, ok = cfg.Target, true
+				value = cfg.Apply(value)
+			}
+			g.logResolved(input, resolved, ok, value)
+			if !ok {
+				g.debugLn(fmt.Sprintf("Button unknown: %v\n", event.Axis))
+				continue
+			}
+
+			g.debugLn(fmt.Sprintf("Axis, input: %v, resolved as: %v\n", event.Axis, resolved))
+
+			g.releaseSpikes(resolved)
+			if g.spike(resolved, value) {
+				continue
+			}
+			g.setAxis(resolved, value)
+
+			if resolved == DPadXAxis || resolved == DPadYAxis {
+				if err := g.emitDirection(DPadGroup, g.dpadHandler, g.dpadHandler64, DPadXAxis, DPadYAxis); err != nil {
 					g.debugLn(err.Error())
 				}
+				g.emitDPadButtons()
 				continue
 			}
 
-			if resolved == R2Axis {
-				if err := g.processButton(g.r2Btn, pos); err != nil {
-					g.debugLn(err.Error())
-				}
+			if isStickAxis(resolved) {
+				g.emitStick(resolved)
+				continue
+			}
+
+			if resolved == TouchpadXAxis || resolved == TouchpadYAxis {
+				g.emitTouchpad()
+				continue
+			}
+
+			// L2 and R2 are axis, delivered both as analog values and as buttons
+			if resolved == L2Axis || resolved == R2Axis {
+				g.handleTrigger(resolved, value, g.triggerPosition(resolved, value))
 				continue
 			}
 
 			g.debugLn(fmt.Sprintf("Axis event, axis: %v, value: %v, when: %v\n", event.Axis, event.Value, event.When))
+
+		case err := <-g.device.OnError():
+			g.debugLn(fmt.Sprintf("Device error: %v\n", err))
+			if g.errorHandler != nil {
+				g.errorHandler(err)
+			}
+			if g.lifecycle != nil {
+				g.lifecycle(LifecycleEvent{Kind: DeviceError, Gamepad: g, Err: err})
+			}
+
+		case <-g.spikeRelease:
+			g.spikeRelease = nil
+			g.releaseSpikes(-1)
+
+		case done := <-g.flushCh:
+			g.flush()
+			close(done)
+
+		case req := <-g.pauseCh:
+			g.setPaused(req.paused)
+			close(req.done)
+
+		case done := <-g.reconnectCh:
+			if !g.reconnectNow(done) {
+				g.deviceLost()
+				return
+			}
 		}
 	}
 }
 
-func (g *Gamepad) emitDirection(handler directionHandler, xIndex, yIndex Resolved) error {
-	if handler == nil {
-		return errors.New("handler not assigned")
+// Flush re-emits the cached dpad, stick and trigger values to the handlers, e.g. to resync after a pause without waiting
+// for the next physical movement. It blocks until the event loop has done so, or ctx is done.
+func (g *Gamepad) Flush(ctx context.Context) error {
+	done := make(chan struct{})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	case g.flushCh <- done:
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+		return nil
+	}
+}
+
+func (g *Gamepad) flush() {
+	if err := g.emitDirection(DPadGroup, g.dpadHandler, g.dpadHandler64, DPadXAxis, DPadYAxis); err != nil {
+		g.debugLn(err.Error())
+	}
+	if err := g.emitDirection(LeftStickGroup, g.leftJoyHandler, g.leftJoyHandler64, LeftJoyXAxis, LeftJoyYAxis); err != nil {
+		g.debugLn(err.Error())
+	}
+	if err := g.emitDirection(RightStickGroup, g.rightJoyHandler, g.rightJoyHandler64, RightJoyXAxis, RightJoyYAxis); err != nil {
+		g.debugLn(err.Error())
 	}
+	g.emitTrigger(LeftTriggerGroup, g.axisCache[L2Axis])
+	g.emitTrigger(RightTriggerGroup, g.axisCache[R2Axis])
+}
 
+func (g *Gamepad) emitDirection(group AxisGroup, handler directionHandler, handler64 directionHandler64, xIndex, yIndex Resolved) error {
 	x := g.axisCache[xIndex]
 	y := g.axisCache[yIndex]
 
 	if g.invertY {
 		y = y * -1
 	}
-	xx := float32(x) / MaxValue
-	yy := float32(y) / MaxValue
 
-	if xx < -1 {
-		xx = -1
+	// Normalize in float64 so the full int16 resolution survives for the 64-bit handlers
+	xx := float64(x) / MaxValue
+	yy := float64(y) / MaxValue
+	xx, yy = g.recenter(group, xx, yy)
+
+	if math.Abs(xx) < g.axisNoise[xIndex] {
+		xx = 0
+	}
+	if math.Abs(yy) < g.axisNoise[yIndex] {
+		yy = 0
+	}
+	touching := xx != 0 || yy != 0
+
+	if d, ok := g.axisDeadzones[group]; ok {
+		xx = axisDeadzone(xx, d[0])
+		yy = axisDeadzone(yy, d[1])
+	}
+
+	if rad, ok := g.rotations[xIndex]; ok {
+		sin, cos := math.Sincos(rad)
+		xx, yy = xx*cos-yy*sin, xx*sin+yy*cos
+	}
+
+	if tolerance, ok := g.snaps[group]; ok {
+		xx, yy = snapAngle(xx, yy, tolerance)
+	}
+
+	if group == DPadGroup && g.normalizeDPad {
+		if m := math.Hypot(xx, yy); m > 1 {
+			xx, yy = xx/m, yy/m
+		}
+	}
+
+	if c := g.clamp; c != nil {
+		xx = math.Min(math.Max(xx, c[0]), c[1])
+		yy = math.Min(math.Max(yy, c[0]), c[1])
+	}
+
+	xx, yy, ok := g.filterAxis(group, xx, yy)
+	if !ok {
+		return nil
+	}
+
+	if group == LeftStickGroup && touching != g.leftTouching {
+		g.leftTouching = touching
+		if g.leftTouchHandler != nil {
+			g.leftTouchHandler(touching)
+		}
+	}
+
+	delivered := false
+	if group == LeftStickGroup && g.leftJoy8WayHandler != nil {
+		// Undo the inversion so snapping works on the physical direction
+		up := yy * YAxisUp
+		if g.invertY {
+			up = -up
+		}
+		if dir := snap8Way(xx, up, float64(g.deadzone8Way)); dir != g.leftJoy8Way {
+			g.leftJoy8Way = dir
+			g.leftJoy8WayHandler(dir)
+		}
+		delivered = true
+	}
+
+	if group == LeftStickGroup && g.leftJoyDeltaHandler != nil {
+		if math.Hypot(xx, yy) < deltaCenterRadius {
+			g.leftJoyLast = [2]float64{}
+		} else {
+			dx, dy := xx-g.leftJoyLast[0], yy-g.leftJoyLast[1]
+			g.leftJoyLast = [2]float64{xx, yy}
+			g.timed(func() { g.leftJoyDeltaHandler(float32(dx), float32(dy)) }, "delta handler, group: %v", group)
+		}
+		delivered = true
+	}
+
+	if group == RightStickGroup {
+		g.mu.Lock()
+		g.rightStick = [2]float64{xx, yy}
+		g.mu.Unlock()
 	}
-	if xx > 1 {
-		xx = 1
+
+	if g.publish(InputEvent{Kind: AxisInput, Group: group, X: float32(xx), Y: float32(yy)}) {
+		delivered = true
 	}
 
-	if yy < -1 {
-		yy = -1
+	if r := g.outputRange; r != nil {
+		xx = r[0] + (xx+1)/2*(r[1]-r[0])
+		yy = r[0] + (yy+1)/2*(r[1]-r[0])
+	}
+	if g.axisHandler != nil {
+		g.timed(func() { g.axisHandler(group, float32(xx), float32(yy)) }, "axis change handler, group: %v", group)
+		delivered = true
+	}
+	if handler != nil {
+		g.timed(func() { handler(float32(xx), float32(yy)) }, "direction handler, group: %v", group)
+		delivered = true
+	}
+	if handler64 != nil {
+		g.timed(func() { handler64(xx, yy) }, "direction handler, group: %v", group)
+		delivered = true
 	}
-	if yy > 1 {
-		yy = 1
+
+	if !delivered {
+		return errors.New("handler not assigned")
 	}
-	handler(xx, yy) // TODO scale to float
 	return nil
 }
 
+// snapAngle turns a position to the nearest of the 8 directions when it's within tolerance radians of it, keeping the magnitude
+func snapAngle(x, y, tolerance float64) (float64, float64) {
+	m := math.Hypot(x, y)
+	if m == 0 {
+		return x, y
+	}
+
+	angle := math.Atan2(y, x)
+	nearest := math.Round(angle/(math.Pi/4)) * (math.Pi / 4)
+	if math.Abs(angle-nearest) > tolerance {
+		return x, y
+	}
+	sin, cos := math.Sincos(nearest)
+	return m * cos, m * sin
+}
+
+// checkAlignment reports both sticks starting or ending to point the same way, from the cached axes.
+// Directions are physical like OnLeftStick8Way.
+func (g *Gamepad) checkAlignment() {
+	if g.alignedHandler == nil {
+		return
+	}
+
+	lx := float64(g.axisCache[LeftJoyXAxis]) / MaxValue
+	lup := float64(g.axisCache[LeftJoyYAxis]*YAxisUp) / MaxValue
+	rx := float64(g.axisCache[RightJoyXAxis]) / MaxValue
+	rup := float64(g.axisCache[RightJoyYAxis]*YAxisUp) / MaxValue
+
+	left, right := math.Hypot(lx, lup), math.Hypot(rx, rup)
+	aligned := false
+	if left >= g.alignMagnitude && right >= g.alignMagnitude {
+		diff := math.Abs(math.Atan2(lup, lx) - math.Atan2(rup, rx))
+		if diff > math.Pi {
+			diff = 2*math.Pi - diff
+		}
+		aligned = diff <= g.alignTolerance
+	}
+
+	if aligned == g.aligned {
+		return
+	}
+	g.aligned = aligned
+
+	if !aligned {
+		g.alignedHandler(Neutral, 0)
+		return
+	}
+	magnitude := math.Min(math.Min(left, right), 1)
+	g.alignedHandler(snap8Way(lx+rx, lup+rup, 0), float32(magnitude))
+}
+
+// snap8Way picks the compass direction closest to the vector, where up is positive
+func snap8Way(x, up, deadzone float64) Direction8 {
+	if math.Hypot(x, up) < deadzone {
+		return Neutral
+	}
+
+	// Sectors are 45 degrees wide, counter-clockwise from East
+	sector := int(math.Round(math.Atan2(up, x)/(math.Pi/4))+8) % 8
+	return [...]Direction8{East, NorthEast, North, NorthWest, West, SouthWest, South, SouthEast}[sector]
+}
+
+// triggerValue normalizes a trigger reading from its full -MaxValue..MaxValue range to 0..1
+func triggerValue(v int) float32 {
+	t := float32(v+MaxValue) / (2 * MaxValue)
+	if t < 0 {
+		t = 0
+	}
+	if t > 1 {
+		t = 1
+	}
+	return t
+}
+
+// emitTouchpad reports the finger position normalized to 0..1 across the pad, the last position is kept while not touching
+func (g *Gamepad) emitTouchpad() {
+	x, y := g.axisCache[TouchpadXAxis], g.axisCache[TouchpadYAxis]
+	touching := x >= 0 && y >= 0
+	if touching {
+		fx, fy, ok := g.filterAxis(TouchpadGroup, float64(x)/MaxValue, float64(y)/MaxValue)
+		if !ok {
+			return
+		}
+		g.touchLast = [2]float32{float32(fx), float32(fy)}
+	}
+
+	g.publish(InputEvent{Kind: AxisInput, Group: TouchpadGroup, X: g.touchLast[0], Y: g.touchLast[1]})
+	if g.touchpadHandler != nil {
+		g.timed(func() { g.touchpadHandler(g.touchLast[0], g.touchLast[1], touching) }, "touchpad handler")
+	}
+}
+
+// emitDPadButtons feeds the dpad axes through the button state machine as four buttons.
+// Up is physical, it doesn't follow WithInvertedY.
+func (g *Gamepad) emitDPadButtons() {
+	x := g.axisCache[DPadXAxis]
+	up := g.axisCache[DPadYAxis] * YAxisUp
+
+	g.dpadButton(DPadUpButton, up > MaxValue/2)
+	g.dpadButton(DPadDownButton, up < -MaxValue/2)
+	g.dpadButton(DPadLeftButton, x < -MaxValue/2)
+	g.dpadButton(DPadRightButton, x > MaxValue/2)
+}
+
+// emitDPadAxes feeds the dpad direction handlers from a dpad made of buttons, so OnDPad behaves the same as on a hat.
+// A device with dpad axes feeds them itself and is left alone.
+func (g *Gamepad) emitDPadAxes() {
+	if g.hasDPadAxes() {
+		return
+	}
+
+	x, up := 0, 0
+	if len(g.inputsDown[DPadRightButton]) > 0 {
+		x += MaxValue
+	}
+	if len(g.inputsDown[DPadLeftButton]) > 0 {
+		x -= MaxValue
+	}
+	if len(g.inputsDown[DPadUpButton]) > 0 {
+		up += MaxValue
+	}
+	if len(g.inputsDown[DPadDownButton]) > 0 {
+		up -= MaxValue
+	}
+	g.setAxis(DPadXAxis, x)
+	g.setAxis(DPadYAxis, up*YAxisUp)
+
+	if err := g.emitDirection(DPadGroup, g.dpadHandler, g.dpadHandler64, DPadXAxis, DPadYAxis); err != nil {
+		g.debugLn(err.Error())
+	}
+}
+
+// mapsTo reports whether any input of the mapping, or of the axis mapping, resolves to r
+func (g *Gamepad) mapsTo(r Resolved) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, cfg := range g.axisMapping {
+		if cfg.Target == r {
+			return true
+		}
+	}
+	for _, resolved := range g.inputMapping {
+		if resolved == r {
+			return true
+		}
+	}
+	return false
+}
+
+// guideStandsIn reports whether resolved is AnalogButton pressed by the Guide button standing in for it, as nothing maps
+// to it. It then only reaches OnAnalog and WithAnalogAsToggle, the press is published once as the Guide button's.
+func (g *Gamepad) guideStandsIn(resolved Resolved) bool {
+	return resolved == AnalogButton && !g.mapsTo(AnalogButton)
+}
+
+// hasDPadAxes reports whether the mapping has inputs for the dpad axes
+func (g *Gamepad) hasDPadAxes() bool {
+	return g.mapsTo(DPadXAxis) || g.mapsTo(DPadYAxis)
+}
+
+func (g *Gamepad) dpadButton(resolved Resolved, down bool) {
+	pos := UpPosition
+	if down {
+		pos = DownPosition
+	}
+
+	g.emitRaw(resolved, pos)
+	pos = g.coalesce(resolved, dpadAxesInput, pos)
+	if err := g.processButton(resolved, pos); err != nil {
+		g.debugLn(err.Error())
+	}
+}
+
+// dpadAxesInput stands for the dpad axes as a source of the dpad buttons, alongside any button inputs mapped to them
+var dpadAxesInput = Input{Type: -1}
+
+// coalesce records the position of one input mapped to a button and returns the position of the button,
+// which is down while any of its inputs is down
+func (g *Gamepad) coalesce(resolved Resolved, in Input, pos ButtonPosition) ButtonPosition {
+	down := g.inputsDown[resolved]
+	if pos == DownPosition {
+		if down == nil {
+			down = make(map[Input]bool)
+			g.inputsDown[resolved] = down
+		}
+		down[in] = true
+		return DownPosition
+	}
+
+	delete(down, in)
+	if len(down) > 0 {
+		return DownPosition
+	}
+	return UpPosition
+}
+
+// triggerPosition converts a trigger reading to a button position. Each trigger keeps its own last position
+// so that however the value ramps, a Down is always followed by an Up once the trigger is back near rest.
+func (g *Gamepad) triggerPosition(resolved Resolved, value int) ButtonPosition {
+	last := g.triggerPositions[resolved]
+	pos := last
+	press, release := float32(triggerThreshold), float32(triggerThreshold)
+	if h := g.triggerHysteresis; h != nil {
+		press, release = h[0], h[1]
+	}
+
+	t := triggerValue(value)
+	switch {
+	case last == UpPosition && t > press:
+		pos = DownPosition
+	case last == DownPosition && t <= release:
+		pos = UpPosition
+	}
+	g.triggerPositions[resolved] = pos
+	return pos
+}
+
+// handleTrigger delivers one trigger event to the analog consumers and then to the button state machine
+func (g *Gamepad) handleTrigger(resolved Resolved, value int, pos ButtonPosition) {
+	group := LeftTriggerGroup
+	if resolved == R2Axis {
+		group = RightTriggerGroup
+	}
+	g.emitTrigger(group, value)
+	g.emitRaw(resolved, pos)
+	if err := g.processButton(resolved, pos); err != nil {
+		g.debugLn(err.Error())
+	}
+}
+
+func (g *Gamepad) emitTrigger(group AxisGroup, value int) {
+	filtered, _, ok := g.filterAxis(group, float64(triggerValue(value)), 0)
+	if !ok {
+		return
+	}
+	t := float32(filtered)
+	g.publish(InputEvent{Kind: AxisInput, Group: group, X: t})
+	if g.axisHandler != nil {
+		g.axisHandler(group, t, 0)
+	}
+	analog := g.l2AxisHandler
+	if group == RightTriggerGroup {
+		analog = g.r2AxisHandler
+	}
+	if analog != nil {
+		analog(t)
+	}
+	if g.pedalHandler != nil {
+		g.pedalHandler(triggerValue(g.axisCache[R2Axis]) - triggerValue(g.axisCache[L2Axis]))
+	}
+
+	h := g.l2StageHandler
+	if group == RightTriggerGroup {
+		h = g.r2StageHandler
+	}
+	if h != nil {
+		if stage := triggerStage(g.triggerStages[group], t, g.softThreshold); stage != g.triggerStages[group] {
+			g.triggerStages[group] = stage
+			h(stage)
+		}
+	}
+}
+
+// triggerStage moves a two stage trigger between stages, with hysteresis against the last stage so a trigger
+// resting on a threshold doesn't flicker
+func triggerStage(last int, t, soft float32) int {
+	thresholds := [...]float32{soft, fullThreshold}
+
+	stage := 0
+	for i, threshold := range thresholds {
+		if i < last {
+			threshold -= stageHysteresis
+		}
+		if t >= threshold {
+			stage = i + 1
+		}
+	}
+	return stage
+}
+
+// classifyPress returns the events a completed press produces, in order. processButton only takes the click
+// decision from it, hold events are delivered by the hold timer while the button is still down and the ones
+// returned here are what that timer delivers for a press of this length.
+func classifyPress(downTime, upTime time.Time, clickDur, holdDur time.Duration) []ButtonEvent {
+	elapsed := upTime.Sub(downTime)
+	if elapsed >= holdDur {
+		return []ButtonEvent{DownEvent, PressAndHoldEvent, HoldEvent, UpEvent}
+	}
+	if elapsed < clickDur {
+		return []ButtonEvent{DownEvent, UpEvent, ClickEvent}
+	}
+	return []ButtonEvent{DownEvent, UpEvent}
+}
+
 func includes(events []ButtonEvent, event ButtonEvent) bool {
 	if events == nil {
 		return true
@@ -462,49 +2625,371 @@ func includes(events []ButtonEvent, event ButtonEvent) bool {
 	return false
 }
 
-func (g *Gamepad) processButton(btn *button, pos ButtonPosition) error {
-	if btn == nil {
-		return errors.New("handler not assigned")
+func (g *Gamepad) emitRaw(resolved Resolved, pos ButtonPosition) {
+	g.mu.Lock()
+	handler := g.rawHandlers[resolved]
+	g.mu.Unlock()
+
+	if handler != nil && g.passes(resolved, positionEvent(pos)) {
+		handler(pos)
+	}
+}
+
+// positionEvent is the event a button in pos was moved by
+func positionEvent(pos ButtonPosition) ButtonEvent {
+	if pos == DownPosition {
+		return DownEvent
+	}
+	return UpEvent
+}
+
+// setButton subscribes h to the events of a button, under the lock as the event loop reads the slot at any time
+func (g *Gamepad) setButton(resolved Resolved, h buttonHandler, events []ButtonEvent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	*g.buttonRef(resolved) = &button{
+		handler: h,
+		events:  events,
+	}
+}
+
+// buttonRef returns the subscription slot for a resolved button
+func (g *Gamepad) buttonRef(resolved Resolved) **button {
+	switch resolved {
+	case CrossButton:
+		return &g.crossBtn
+	case CircleButton:
+		return &g.circleBtn
+	case SquareButton:
+		return &g.squareBtn
+	case TriangleButton:
+		return &g.triangleBtn
+	case L1Button:
+		return &g.l1Btn
+	case R1Button:
+		return &g.r1Btn
+	case L2Axis:
+		return &g.l2Btn
+	case R2Axis:
+		return &g.r2Btn
+	case SelectButton:
+		return &g.selectBtn
+	case StartButton:
+		return &g.startBtn
+	case AnalogButton:
+		return &g.analogBtn
+	case LeftJoyButton:
+		return &g.ljBtn
+	case RightJoyButton:
+		return &g.rjBtn
+	case GuideButton:
+		return &g.guideBtn
+	case DPadUpButton:
+		return &g.dpadUpBtn
+	case DPadDownButton:
+		return &g.dpadDownBtn
+	case DPadLeftButton:
+		return &g.dpadLeftBtn
+	case DPadRightButton:
+		return &g.dpadRightBtn
+	}
+	return nil
+}
+
+// fire delivers an event to the subscribed handler and the library's own consumers
+func (g *Gamepad) fire(resolved Resolved, btn *button, event ButtonEvent) {
+	resolved, btn, event, ok := g.filterButton(resolved, btn, event)
+	if !ok {
+		return
+	}
+
+	if event == ClickEvent || event == HoldEvent {
+		g.mu.Lock()
+		g.pressCounts[resolved]++
+		g.mu.Unlock()
+	}
+
+	if event == ClickEvent {
+		g.flipToggle(resolved)
+		g.flipSticky(resolved)
+	}
+
+	if !g.guideStandsIn(resolved) {
+		g.publish(InputEvent{Kind: ButtonInput, Button: resolved, Event: event})
+	}
+
+	if g.fireModified(resolved, event) {
+		return
+	}
+
+	if btn != nil && includes(btn.events, event) {
+		handler := btn.handler
+		g.dispatch(resolved, func() {
+			g.timed(func() { handler(event) }, "button handler, button: %v, event: %v", resolved, event)
+		})
+	}
+}
+
+// ErrSlowHandler is reported through OnError when a handler runs longer than WithHandlerTimeout allows
+var ErrSlowHandler = errors.New("handler exceeded timeout")
+
+// timed runs a handler, reporting it when it takes longer than the handler timeout. The description is only formatted when it does.
+func (g *Gamepad) timed(f func(), format string, args ...interface{}) {
+	if g.handlerTimeout <= 0 {
+		f()
+		return
+	}
+
+	start := time.Now()
+	f()
+	if elapsed := time.Since(start); elapsed > g.handlerTimeout {
+		err := fmt.Errorf("%w: %v took %v", ErrSlowHandler, fmt.Sprintf(format, args...), elapsed)
+		log.Println(err)
+		if g.errorHandler != nil {
+			g.errorHandler(err)
+		}
+	}
+}
+
+// fireModified calls the modifier bindings of a button whose modifier is down, reporting whether any was called
+func (g *Gamepad) fireModified(resolved Resolved, event ButtonEvent) bool {
+	var handlers []buttonHandler
+	g.mu.Lock()
+	for _, m := range g.modifiers[resolved] {
+		state, ok := g.buttonStates[m.modifier]
+		if ok && state.lastPosition == DownPosition && includes(m.events, event) {
+			handlers = append(handlers, m.handler)
+		}
 	}
+	g.mu.Unlock()
 
-	if btn.lastPosition == pos {
+	for _, h := range handlers {
+		h := h
+		g.dispatch(resolved, func() {
+			g.timed(func() { h(event) }, "modifier button handler, button: %v, event: %v", resolved, event)
+		})
+	}
+	return len(handlers) > 0
+}
+
+func (g *Gamepad) flipToggle(resolved Resolved) {
+	g.mu.Lock()
+	t, ok := g.toggles[resolved]
+	if !ok {
+		g.mu.Unlock()
+		return
+	}
+	t.on = !t.on
+	on, handler := t.on, t.handler
+	g.mu.Unlock()
+
+	if handler != nil {
+		handler(on)
+	}
+}
+
+// repeatButton delivers a position the button is already in as another Down or Up, when WithoutDuplicateFilter is used.
+// processButton still swallows it, so click and hold detection only ever see the first.
+func (g *Gamepad) repeatButton(resolved Resolved, pos ButtonPosition) {
+	if !g.noDupFilter {
+		return
+	}
+
+	g.mu.Lock()
+	state, ok := g.buttonStates[resolved]
+	repeated := ok && state.lastPosition == pos
+	btn := *g.buttonRef(resolved)
+	g.mu.Unlock()
+
+	if !repeated {
+		return
+	}
+	if pos == DownPosition {
+		g.fire(resolved, btn, DownEvent)
+	} else {
+		g.fire(resolved, btn, UpEvent)
+	}
+}
+
+func (g *Gamepad) processButton(resolved Resolved, pos ButtonPosition) error {
+	state, ok := g.buttonStates[resolved]
+	if !ok {
+		return fmt.Errorf("not a button: %v", resolved)
+	}
+
+	g.mu.Lock()
+	if state.lastPosition == pos {
+		g.mu.Unlock()
 		return nil // Swallow duplicate events
 	}
+	state.lastPosition = pos
+	if pos == DownPosition {
+		state.downTime = time.Now()
+		state.held = false
+	}
+	downTime := state.downTime
+	btn := *g.buttonRef(resolved)
+	g.mu.Unlock()
+
+	if g.changedHandler != nil && !g.guideStandsIn(resolved) && g.passes(resolved, positionEvent(pos)) {
+		if pos == DownPosition {
+			g.changedHandler([]Resolved{resolved}, nil)
+		} else {
+			g.changedHandler(nil, []Resolved{resolved})
+		}
+	}
 
 	switch pos {
 	case DownPosition:
-		btn.downTime = time.Now()
-		if includes(btn.events, DownEvent) {
-			btn.handler(ButtonEvent(pos))
+		g.fire(resolved, btn, DownEvent)
+		if g.wantsHold(resolved, btn) {
+			g.scheduleHold(resolved, state, btn, g.holdDuration)
 		}
-		if includes(btn.events, HoldEvent) {
-			if btn.holdTimer != nil {
-				btn.holdTimer.Stop()
-			}
-
-			btn.holdTimer = time.AfterFunc(g.holdDuration, func() {
-				btn.handler(HoldEvent)
-			})
+		if g.latchTurbo(resolved) {
+			g.scheduleTurbo(resolved, state, btn)
 		}
+		g.scheduleTiers(resolved, state)
 	case UpPosition:
-		if btn.holdTimer != nil {
-			btn.holdTimer.Stop()
+		g.stopHold(state)
+		g.fire(resolved, btn, UpEvent)
+
+		upTime := time.Now()
+		if held := upTime.Sub(downTime); held >= g.holdDuration {
+			g.mu.Lock()
+			h := g.holdReleases[resolved]
+			g.mu.Unlock()
+			if h != nil && g.passes(resolved, UpEvent) {
+				g.timed(func() { h(held) }, "hold release handler, button: %v", resolved)
+			}
 		}
-
-		if includes(btn.events, UpEvent) {
-			btn.handler(ButtonEvent(pos))
+		// Only a press something held for excludes a click, otherwise a long press still clicks if the click duration allows
+		holdDur := g.holdDuration
+		if !g.wantsHold(resolved, btn) {
+			holdDur = math.MaxInt64
 		}
-
-		if includes(btn.events, ClickEvent) {
-			if time.Since(btn.downTime) < g.clickDuration {
-				btn.handler(ClickEvent)
-			} else {
-				g.debugLn(fmt.Sprintf("Invalid click, elapsed: %v, click dur: %v\n", time.Since(btn.downTime), g.clickDuration))
-			}
+		if includes(classifyPress(downTime, upTime, g.clickDuration, holdDur), ClickEvent) {
+			g.fire(resolved, btn, ClickEvent)
+		} else if btn != nil && includes(btn.events, ClickEvent) {
+			g.debugLn(fmt.Sprintf("Invalid click, elapsed: %v, click dur: %v\n", upTime.Sub(downTime), g.clickDuration))
 		}
 	}
 
-	btn.lastPosition = pos
+	if btn == nil && !g.consumed(resolved) {
+		return errors.New("handler not assigned")
+	}
 	return nil
+}
+
+// consumed reports whether the library itself acts on a button, e.g. as a toggle
+func (g *Gamepad) consumed(resolved Resolved) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	_, toggle := g.toggles[resolved]
+	_, sticky := g.stickyHolds[resolved]
+	_, holdRelease := g.holdReleases[resolved]
+	_, tiers := g.holdTiers[resolved]
+	turboToggle := g.turboToggle != nil && *g.turboToggle == resolved
+	return toggle || sticky || holdRelease || tiers || turboToggle || len(g.modifiers[resolved]) > 0
+}
+
+// wantsHold reports whether anything needs the hold timer for a press of the button
+func (g *Gamepad) wantsHold(resolved Resolved, btn *button) bool {
+	if btn != nil && (includes(btn.events, HoldEvent) || includes(btn.events, PressAndHoldEvent)) {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, m := range g.modifiers[resolved] {
+		if includes(m.events, HoldEvent) || includes(m.events, PressAndHoldEvent) {
+			return true
+		}
+	}
+	return g.holdWanted[resolved] > 0
+}
+
+// flipSticky starts a sticky hold on a click of the button, or ends the active one
+func (g *Gamepad) flipSticky(resolved Resolved) {
+	g.mu.Lock()
+	s, ok := g.stickyHolds[resolved]
+	if !ok {
+		g.mu.Unlock()
+		return
+	}
+	s.active = !s.active
+	active, handler := s.active, s.handler
+	g.mu.Unlock()
+
+	if handler != nil {
+		handler(active)
+	}
+}
+
+// scheduleHold fires HoldEvent after d, re-arming every holdRepeat until the press it was scheduled for ends.
+// The first hold of a press is preceded by PressAndHoldEvent.
+func (g *Gamepad) scheduleHold(resolved Resolved, state *buttonState, btn *button, d time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if state.holdTimer != nil {
+		state.holdTimer.Stop()
+	}
+
+	press := state.downTime
+	state.holdTimer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		active := state.lastPosition == DownPosition && state.downTime.Equal(press)
+		first := !state.held
+		if active {
+			state.held = true
+		}
+		g.mu.Unlock()
+		if !active {
+			return
+		}
+
+		if first {
+			g.fire(resolved, btn, PressAndHoldEvent)
+		}
+		g.fire(resolved, btn, HoldEvent)
+		if g.holdRepeat > 0 {
+			g.scheduleHold(resolved, state, btn, g.holdRepeat)
+		}
+	})
+}
 
+func (g *Gamepad) stopHold(state *buttonState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if state.holdTimer != nil {
+		state.holdTimer.Stop()
+	}
+	if state.turboTimer != nil {
+		state.turboTimer.Stop()
+	}
+	for _, t := range state.tierTimers {
+		t.Stop()
+	}
+	state.tierTimers = nil
+}
+
+// scheduleTiers starts a timer for each hold tier of the button, a tier only fires while the press it was started for lasts
+func (g *Gamepad) scheduleTiers(resolved Resolved, state *buttonState) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	press := state.downTime
+	for _, tier := range g.holdTiers[resolved] {
+		tier := tier
+		state.tierTimers = append(state.tierTimers, time.AfterFunc(tier.Duration, func() {
+			g.mu.Lock()
+			active := state.lastPosition == DownPosition && state.downTime.Equal(press) && !g.paused
+			g.mu.Unlock()
+			if active && tier.Handler != nil {
+				g.timed(tier.Handler, "hold tier handler, button: %v, tier: %v", resolved, tier.Duration)
+			}
+		}))
+	}
 }
@@ -0,0 +1,424 @@
+package gamepad
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/gooseclip/pi-gamepad/hid"
+)
+
+// testDriver is the driver the test recordings are made with, its mapping is xpadMapping
+const testDriver = "Microsoft X-Box 360 pad"
+
+// recorded is one event of a test recording, typ is 1 for a button and 2 for an axis as in the recording format
+type recorded struct {
+	typ   uint8
+	index uint8
+	value int16
+}
+
+func buttonAt(index uint8, value int16) recorded {
+	return recorded{typ: 1, index: index, value: value}
+}
+
+func axisAt(index uint8, value int16) recorded {
+	return recorded{typ: 2, index: index, value: value}
+}
+
+// recording encodes events as a recording of the test driver, see hid/replay.go for the format
+func recording(events ...recorded) io.Reader {
+	var buf bytes.Buffer
+	buf.WriteString("PGRC")
+	buf.WriteByte(1)
+	buf.WriteByte(byte(len(testDriver)))
+	buf.WriteString(testDriver)
+	for _, e := range events {
+		binary.Write(&buf, binary.LittleEndian, struct {
+			Delta uint32
+			Type  uint8
+			Index uint8
+			Value int16
+		}{0, e.typ, e.index, e.value})
+	}
+	return &buf
+}
+
+// replayGamepad creates a gamepad playing events back one step at a time, nothing is delivered before play
+func replayGamepad(t *testing.T, events []recorded, opts ...option) *Gamepad {
+	t.Helper()
+	opts = append([]option{WithReplay(recording(events...)), WithReplayStepping(), WithManualStart()}, opts...)
+	g, err := NewGamepad(context.Background(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { g.Close() })
+	return g
+}
+
+// play runs the event loop until every event of the replay has been handled and the replay has ended
+func play(t *testing.T, g *Gamepad) {
+	t.Helper()
+	ended := make(chan struct{})
+	g.OnConnectionChange(func(connected bool, driver string, reason DisconnectReason) {
+		if !connected {
+			close(ended)
+		}
+	})
+
+	go g.Run(context.Background())
+	for g.Step() {
+	}
+	select {
+	case <-ended:
+	case <-time.After(time.Second):
+		t.Fatal("replay didn't end")
+	}
+}
+
+func TestRemapLeavesOtherGamepadsAlone(t *testing.T) {
+	a := replayGamepad(t, []recorded{buttonAt(0, 1), buttonAt(0, 0)})
+	b := replayGamepad(t, []recorded{buttonAt(0, 1), buttonAt(0, 0)})
+
+	// Remap Cross to Circle on a only, as a profile would
+	in := Input{Type: InputTypeButton, Value: 0}
+	a.mu.Lock()
+	a.inputMapping[in] = CircleButton
+	a.mu.Unlock()
+
+	var aCircles, bCrosses, bCircles int
+	a.OnCircle(func(ButtonEvent) { aCircles++ }, DownEvent)
+	b.OnCross(func(ButtonEvent) { bCrosses++ }, DownEvent)
+	b.OnCircle(func(ButtonEvent) { bCircles++ }, DownEvent)
+	play(t, a)
+	play(t, b)
+
+	if aCircles != 1 {
+		t.Errorf("remapped gamepad got %v Circle presses, want 1", aCircles)
+	}
+	if bCrosses != 1 || bCircles != 0 {
+		t.Errorf("other gamepad got %v Cross and %v Circle presses, want 1 and 0", bCrosses, bCircles)
+	}
+	if r := DriverMapping[testDriver][in]; r != CrossButton {
+		t.Errorf("DriverMapping resolves button 0 as %v, want CrossButton", r)
+	}
+}
+
+// dpadEvents records what the dpad handlers see, buttons and directions in the order they're called
+func dpadEvents(g *Gamepad) *[]string {
+	var events []string
+	record := func(name string) buttonHandler {
+		return func(e ButtonEvent) { events = append(events, fmt.Sprint(name, " ", e)) }
+	}
+	g.OnDPadUp(record("up"))
+	g.OnDPadDown(record("down"))
+	g.OnDPadLeft(record("left"))
+	g.OnDPadRight(record("right"))
+	g.OnDPad(func(x, y float32) { events = append(events, fmt.Sprint("dpad ", x, " ", y)) })
+	return &events
+}
+
+func TestDPadRepresentationsMatch(t *testing.T) {
+	up := int16(MaxValue * YAxisUp)
+	hat := replayGamepad(t, []recorded{
+		axisAt(7, up), axisAt(7, 0),
+		axisAt(6, MaxValue), axisAt(7, up), axisAt(7, 0), axisAt(6, 0),
+	})
+
+	m := DriverMapping[testDriver].Copy()
+	delete(m, Input{Type: InputTypeAxis, Value: 6})
+	delete(m, Input{Type: InputTypeAxis, Value: 7})
+	m[Input{Type: InputTypeButton, Value: 11}] = DPadUpButton
+	m[Input{Type: InputTypeButton, Value: 12}] = DPadDownButton
+	m[Input{Type: InputTypeButton, Value: 13}] = DPadLeftButton
+	m[Input{Type: InputTypeButton, Value: 14}] = DPadRightButton
+	buttons := replayGamepad(t, []recorded{
+		buttonAt(11, 1), buttonAt(11, 0),
+		buttonAt(14, 1), buttonAt(11, 1), buttonAt(11, 0), buttonAt(14, 0),
+	}, WithMapping(m))
+
+	hatEvents, buttonEvents := dpadEvents(hat), dpadEvents(buttons)
+	play(t, hat)
+	play(t, buttons)
+
+	if len(*hatEvents) == 0 {
+		t.Fatal("no dpad events")
+	}
+	if !reflect.DeepEqual(*hatEvents, *buttonEvents) {
+		t.Errorf("dpad events differ\n hat:     %v\n buttons: %v", *hatEvents, *buttonEvents)
+	}
+}
+
+// ramp moves a trigger axis from one value to another in steps
+func ramp(index uint8, from, to, steps int) []recorded {
+	var events []recorded
+	for i := 0; i <= steps; i++ {
+		events = append(events, axisAt(index, int16(from+(to-from)*i/steps)))
+	}
+	return events
+}
+
+func TestTriggerRampPressesOnce(t *testing.T) {
+	var events []recorded
+	for i := 0; i < 3; i++ {
+		events = append(events, ramp(2, -MaxValue, MaxValue, 20)...)
+		events = append(events, ramp(2, MaxValue, -MaxValue, 20)...)
+	}
+	// A release that jumps back to rest from partway down
+	events = append(events, ramp(2, -MaxValue, MaxValue/2, 10)...)
+	events = append(events, axisAt(2, -MaxValue))
+
+	g := replayGamepad(t, events)
+	var got []ButtonEvent
+	g.OnL2(func(e ButtonEvent) { got = append(got, e) }, DownEvent, UpEvent)
+	play(t, g)
+
+	want := []ButtonEvent{DownEvent, UpEvent, DownEvent, UpEvent, DownEvent, UpEvent, DownEvent, UpEvent}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTriggerPosition(t *testing.T) {
+	g := &Gamepad{triggerPositions: map[Resolved]ButtonPosition{L2Axis: UpPosition}}
+	g.triggerHysteresis = &[2]float32{0.6, 0.2}
+
+	// Normalized travel 0.5 doesn't press, 0.7 does and 0.3 stays pressed until 0.1
+	for _, step := range []struct {
+		value int
+		want  ButtonPosition
+	}{
+		{-MaxValue, UpPosition},
+		{0, UpPosition},
+		{MaxValue * 2 / 5, DownPosition},
+		{-MaxValue * 2 / 5, DownPosition},
+		{-MaxValue * 4 / 5, UpPosition},
+		{0, UpPosition},
+	} {
+		if pos := g.triggerPosition(L2Axis, step.value); pos != step.want {
+			t.Errorf("value %v: got %v, want %v", step.value, pos, step.want)
+		}
+	}
+}
+
+func TestNormalizedDPadDiagonal(t *testing.T) {
+	g := replayGamepad(t, []recorded{axisAt(7, int16(MaxValue*YAxisUp)), axisAt(6, MaxValue)}, WithNormalizedDPad())
+	var x, y float32
+	g.OnDPad(func(dx, dy float32) {
+		if dx != 0 && dy != 0 {
+			x, y = dx, dy
+		}
+	})
+	play(t, g)
+
+	if x == 0 {
+		t.Fatal("no diagonal reported")
+	}
+	if m := math.Hypot(float64(x), float64(y)); math.Abs(m-1) > 0.01 {
+		t.Errorf("up+right is (%v, %v), magnitude %v, want 1", x, y, m)
+	}
+}
+
+func TestClassifyPress(t *testing.T) {
+	down := time.Now()
+	click, hold := 300*time.Millisecond, time.Second
+	for _, tt := range []struct {
+		name string
+		held time.Duration
+		want []ButtonEvent
+	}{
+		{"click", 100 * time.Millisecond, []ButtonEvent{DownEvent, UpEvent, ClickEvent}},
+		{"at click duration", click, []ButtonEvent{DownEvent, UpEvent}},
+		{"between click and hold", 600 * time.Millisecond, []ButtonEvent{DownEvent, UpEvent}},
+		{"at hold duration", hold, []ButtonEvent{DownEvent, PressAndHoldEvent, HoldEvent, UpEvent}},
+		{"hold", 2 * time.Second, []ButtonEvent{DownEvent, PressAndHoldEvent, HoldEvent, UpEvent}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyPress(down, down.Add(tt.held), click, hold); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+var errFlaky = errors.New("read failed")
+
+// flakyReader plays first, fails one read as a device would and then plays second as the reconnected device
+type flakyReader struct {
+	first, second io.Reader
+	failed        bool
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if n, err := f.first.Read(p); err != io.EOF {
+		return n, err
+	}
+	if !f.failed {
+		f.failed = true
+		return 0, errFlaky
+	}
+	return f.second.Read(p)
+}
+
+func TestReconnectAfterReadError(t *testing.T) {
+	r := &flakyReader{
+		first:  recording(buttonAt(0, 1), buttonAt(0, 0)),
+		second: recording(buttonAt(0, 1), buttonAt(0, 0)),
+	}
+	g := replayGamepad(t, nil, WithReplay(r), WithAutoReconnect(time.Millisecond))
+
+	type change struct {
+		connected bool
+		reason    DisconnectReason
+	}
+	changes := make(chan change, 4)
+	g.OnConnectionChange(func(connected bool, driver string, reason DisconnectReason) {
+		changes <- change{connected, reason}
+	})
+	errs := make(chan error, 1)
+	g.OnError(func(err error) { errs <- err })
+	var crosses int
+	g.OnCross(func(ButtonEvent) { crosses++ }, DownEvent)
+
+	go g.Run(context.Background())
+	for _, want := range []change{{false, DisconnectIOError}, {true, NotDisconnected}, {false, DisconnectEnded}} {
+		for g.Step() {
+		}
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("got connection change %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no connection change, want %+v", want)
+		}
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, errFlaky) {
+			t.Errorf("got error %v, want %v", err, errFlaky)
+		}
+	default:
+		t.Error("read error not reported")
+	}
+	if crosses != 2 {
+		t.Errorf("got %v presses, want one before and one after the reconnect", crosses)
+	}
+}
+
+func TestStickyHoldLatchesOnClick(t *testing.T) {
+	g := replayGamepad(t, []recorded{buttonAt(0, 1), buttonAt(0, 0), buttonAt(0, 1), buttonAt(0, 0)}, WithStickyHold(CrossButton))
+	var got []bool
+	g.OnStickyHold(CrossButton, func(active bool) { got = append(got, active) })
+	play(t, g)
+
+	if want := []bool{true, false}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPauseHoldsBackEveryButtonHandler(t *testing.T) {
+	g := replayGamepad(t, []recorded{buttonAt(0, 1), buttonAt(0, 0)})
+	var delivered []string
+	g.OnButtonRaw(CrossButton, func(ButtonPosition) { delivered = append(delivered, "raw") })
+	g.OnButtonsChanged(func(pressed, released []Resolved) { delivered = append(delivered, "changed") })
+	g.OnHoldRelease(CrossButton, func(time.Duration) { delivered = append(delivered, "hold release") })
+	g.holdDuration = 0
+
+	g.mu.Lock()
+	g.paused = true
+	g.mu.Unlock()
+	play(t, g)
+
+	if len(delivered) > 0 {
+		t.Errorf("delivered %v while paused", delivered)
+	}
+}
+
+func TestPauseNeutralStopsStickMouse(t *testing.T) {
+	g := replayGamepad(t, nil, WithPauseEmitsNeutral())
+	g.mu.Lock()
+	g.rightStick = [2]float64{1, 0}
+	g.mu.Unlock()
+
+	g.setPaused(true)
+	g.mu.Lock()
+	stick := g.rightStick
+	g.mu.Unlock()
+	if stick != [2]float64{} {
+		t.Errorf("right stick is %v after pausing, want centered", stick)
+	}
+}
+
+func TestCustomMappingSkipsDriverAxes(t *testing.T) {
+	in := Input{Type: InputTypeAxis, Value: 0}
+	DriverAxisMapping[testDriver] = AxisMapping{in: {Target: RightJoyXAxis}}
+	t.Cleanup(func() { delete(DriverAxisMapping, testDriver) })
+
+	for _, tt := range []struct {
+		name string
+		opts []option
+		want float32
+	}{
+		{"mapping", []option{WithMapping(DriverMapping[testDriver])}, 1},
+		{"axis mapping", []option{WithMapping(DriverMapping[testDriver]), WithAxisMapping(AxisMapping{in: {Target: LeftJoyXAxis, Invert: true}})}, -1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			g := replayGamepad(t, []recorded{axisAt(0, MaxValue)}, tt.opts...)
+			var left, right float32
+			g.OnLeftJoystick(func(x, y float32) { left = x })
+			g.OnRightJoystick(func(x, y float32) { right = x })
+			play(t, g)
+
+			if left != tt.want || right != 0 {
+				t.Errorf("left stick x is %v and right %v, want %v and 0", left, right, tt.want)
+			}
+		})
+	}
+}
+
+func TestRawSeesEveryMappedInput(t *testing.T) {
+	m := DriverMapping[testDriver].Copy()
+	m[Input{Type: InputTypeButton, Value: 1}] = CrossButton
+	g := replayGamepad(t, []recorded{buttonAt(0, 1), buttonAt(1, 1), buttonAt(0, 0), buttonAt(1, 0)}, WithMapping(m))
+	var raw []ButtonPosition
+	var events []ButtonEvent
+	g.OnButtonRaw(CrossButton, func(pos ButtonPosition) { raw = append(raw, pos) })
+	g.OnCross(func(e ButtonEvent) { events = append(events, e) }, DownEvent, UpEvent)
+	play(t, g)
+
+	if want := []ButtonPosition{DownPosition, DownPosition, UpPosition, UpPosition}; !reflect.DeepEqual(raw, want) {
+		t.Errorf("raw got %v, want %v", raw, want)
+	}
+	if want := []ButtonEvent{DownEvent, UpEvent}; !reflect.DeepEqual(events, want) {
+		t.Errorf("handler got %v, want %v", events, want)
+	}
+}
+
+func TestGuideStandsInForAnalogOnce(t *testing.T) {
+	g := replayGamepad(t, []recorded{buttonAt(8, 1), buttonAt(8, 0)})
+	var analog []ButtonEvent
+	g.OnAnalog(func(e ButtonEvent) { analog = append(analog, e) }, DownEvent, UpEvent)
+	published := map[Resolved]int{}
+	g.OnEverything(func(e InputEvent) {
+		if e.Kind == ButtonInput && e.Event == DownEvent {
+			published[e.Button]++
+		}
+	})
+	play(t, g)
+
+	if want := []ButtonEvent{DownEvent, UpEvent}; !reflect.DeepEqual(analog, want) {
+		t.Errorf("OnAnalog got %v, want %v", analog, want)
+	}
+	if published[GuideButton] != 1 || published[AnalogButton] != 0 {
+		t.Errorf("published %v Guide and %v Analog presses, want 1 and 0", published[GuideButton], published[AnalogButton])
+	}
+}
@@ -0,0 +1,190 @@
+package hid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Binary log format, all little-endian:
+//
+//	header: magic "PGBL", version uint8
+//	record: kind uint8, time int64 (unix ns), payload length uint16, payload
+//
+// A device record's payload is the driver name, a report's the bytes as read from the device, an event's the decoded
+// type uint8, index uint8, value int16, and a resolved record's the input type uint8, index uint8, target int16, value int32.
+// A target of -1 is an input the mapping doesn't know.
+const (
+	binaryLogMagic   = "PGBL"
+	binaryLogVersion = 1
+)
+
+const (
+	logDevice uint8 = iota + 1
+	logReport
+	logEvent
+	logResolved
+)
+
+// Unresolved is the target logged for an input the mapping doesn't know
+const Unresolved Resolved = -1
+
+type logRecord struct {
+	Kind   uint8
+	Time   int64
+	Length uint16
+}
+
+// BinaryLog writes what a device reports at every stage, the raw reports, the events decoded from them and what the
+// gamepad resolved them as, for DumpBinaryLog to show. Like a Recorder it outlives a single HID.
+type BinaryLog struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started bool
+	err     error
+}
+
+func NewBinaryLog(w io.Writer) *BinaryLog {
+	return &BinaryLog{w: w}
+}
+
+// LogTo writes the device's reports and decoded events to l, starting with a record of the driver
+func (h *HID) LogTo(l *BinaryLog) {
+	h.mu.Lock()
+	h.binaryLog = l
+	h.mu.Unlock()
+	l.write(logDevice, []byte(h.Driver))
+}
+
+func (h *HID) logged() *BinaryLog {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.binaryLog
+}
+
+// logReport writes a report as read from the device
+func (h *HID) logReport(report []byte) {
+	if l := h.logged(); l != nil {
+		l.write(logReport, report)
+	}
+}
+
+// logEvent writes an event decoded from the device's reports
+func (l *BinaryLog) logEvent(evt osEvent) {
+	payload := make([]byte, 4)
+	payload[0], payload[1] = evt.Type, evt.Index
+	binary.LittleEndian.PutUint16(payload[2:], uint16(evt.Value))
+	l.write(logEvent, payload)
+}
+
+// Resolved writes what an input was resolved as by the mapping, Unresolved when it wasn't, and its value
+func (l *BinaryLog) Resolved(in Input, r Resolved, value int) {
+	payload := make([]byte, 8)
+	payload[0], payload[1] = uint8(in.Type), in.Value
+	binary.LittleEndian.PutUint16(payload[2:], uint16(int16(r)))
+	binary.LittleEndian.PutUint32(payload[4:], uint32(int32(value)))
+	l.write(logResolved, payload)
+}
+
+func (l *BinaryLog) write(kind uint8, payload []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.err != nil {
+		return
+	}
+	if len(payload) > 0xffff {
+		payload = payload[:0xffff]
+	}
+
+	if !l.started {
+		l.started = true
+		if _, l.err = l.w.Write(append([]byte(binaryLogMagic), binaryLogVersion)); l.err != nil {
+			log.Printf("Binary log stopped, err: %v", l.err)
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, logRecord{Kind: kind, Time: time.Now().UnixNano(), Length: uint16(len(payload))})
+	buf.Write(payload)
+	if _, l.err = l.w.Write(buf.Bytes()); l.err != nil {
+		log.Printf("Binary log stopped, err: %v", l.err)
+	}
+}
+
+// DumpBinaryLog renders a log written by a BinaryLog as text, a line per record with its time since the first record
+func DumpBinaryLog(r io.Reader, w io.Writer) error {
+	header := make([]byte, len(binaryLogMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+	if string(header[:len(binaryLogMagic)]) != binaryLogMagic {
+		return errors.New("not a binary log")
+	}
+	if v := header[len(binaryLogMagic)]; v != binaryLogVersion {
+		return fmt.Errorf("unsupported binary log version: %v", v)
+	}
+
+	var start int64
+	for i := 0; ; i++ {
+		var rec logRecord
+		if err := binary.Read(r, binary.LittleEndian, &rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("record %v: %w", i, err)
+		}
+		payload := make([]byte, rec.Length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return fmt.Errorf("record %v: %w", i, err)
+		}
+
+		if i == 0 {
+			start = rec.Time
+		}
+		elapsed := time.Duration(rec.Time - start).Seconds()
+		if _, err := fmt.Fprintf(w, "%12.6f %v\n", elapsed, describeRecord(rec.Kind, payload)); err != nil {
+			return err
+		}
+	}
+}
+
+func describeRecord(kind uint8, payload []byte) string {
+	switch {
+	case kind == logDevice:
+		return fmt.Sprintf("device   %v", string(payload))
+	case kind == logReport:
+		return fmt.Sprintf("report   % x", payload)
+	case kind == logEvent && len(payload) == 4:
+		return fmt.Sprintf("event    %v %v = %v", describeEventType(payload[0]), payload[1], int16(binary.LittleEndian.Uint16(payload[2:])))
+	case kind == logResolved && len(payload) == 8:
+		in := Input{Type: int(payload[0]), Value: payload[1]}
+		target := Resolved(int16(binary.LittleEndian.Uint16(payload[2:])))
+		value := int32(binary.LittleEndian.Uint32(payload[4:]))
+		kind := "button"
+		if in.Type == InputTypeAxis {
+			kind = "axis"
+		}
+		if target == Unresolved {
+			return fmt.Sprintf("resolved %v:%v unmapped = %v", kind, in.Value, value)
+		}
+		return fmt.Sprintf("resolved %v:%v -> %v = %v", kind, in.Value, target, value)
+	}
+	return fmt.Sprintf("unknown  kind %v: % x", kind, payload)
+}
+
+func describeEventType(t uint8) string {
+	switch eventType(t &^ 0x80) {
+	case buttonEventType:
+		return "button"
+	case axisEventType:
+		return "axis"
+	}
+	return fmt.Sprintf("type %v", t)
+}
@@ -0,0 +1,75 @@
+package hid
+
+import "sync"
+
+// DecodedInput is one control change decoded from a raw report, Index is the input mapped through DriverMapping
+type DecodedInput struct {
+	Type  int // InputTypeButton or InputTypeAxis
+	Index uint8
+	Value int16
+
+	// Analog marks a pressure sensitive button, Value is then its pressure in 0..MaxValue rather than 0 or 1.
+	// It's turned into a press by ConnectConfig.ButtonThreshold.
+	Analog bool
+}
+
+// Decoder turns a raw input report into control changes. It's called for every report the device sends,
+// so a decoder reporting only changes has to remember the previous report itself.
+type Decoder interface {
+	Decode(report []byte) []DecodedInput
+}
+
+// DecoderFunc adapts a function to a Decoder
+type DecoderFunc func(report []byte) []DecodedInput
+
+func (f DecoderFunc) Decode(report []byte) []DecodedInput {
+	return f(report)
+}
+
+var decoders = struct {
+	sync.Mutex
+	m map[driverName]Decoder
+}{m: make(map[driverName]Decoder)}
+
+// RegisterDecoder makes backends reading raw reports decode them with d for devices of the driver, replacing the built-in decoding.
+// Linux reads the joystick API, which is decoded by the kernel, so only Darwin uses it.
+func RegisterDecoder(driver driverName, d Decoder) {
+	decoders.Lock()
+	defer decoders.Unlock()
+	decoders.m[driver] = d
+}
+
+// applyThreshold turns an analog button into pressed or released by the button threshold, other input is returned as is
+func (h *HID) applyThreshold(in DecodedInput) DecodedInput {
+	if in.Type != InputTypeButton || !in.Analog {
+		return in
+	}
+
+	pressed := in.Value > 0
+	if h.buttonThreshold > 0 {
+		pressed = float32(in.Value) >= h.buttonThreshold*MaxValue
+	}
+	in.Analog = false
+	in.Value = 0
+	if pressed {
+		in.Value = 1
+	}
+	return in
+}
+
+// USBDevice is the USB vendor and product ID of a gamepad model
+type USBDevice struct {
+	Vendor  uint16
+	Product uint16
+}
+
+// USBDevices are the gamepads looked for on USB, the X-Box 360 pad by default. A device added here also needs a decoder
+// registered with RegisterDecoder, unless its reports are those of the 360 pad, which then use the MacOS mapping.
+// Only Darwin uses it, like RegisterDecoder.
+var USBDevices = []USBDevice{{Vendor: 0x045e, Product: 0x028e}}
+
+func decoderFor(driver driverName) Decoder {
+	decoders.Lock()
+	defer decoders.Unlock()
+	return decoders.m[driver]
+}
@@ -2,7 +2,15 @@ package hid
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -28,10 +36,77 @@ const (
 	LeftJoyYAxis
 	RightJoyXAxis
 	RightJoyYAxis
+	// Triggers span the full axis range, resting at -MaxValue and fully pulled at MaxValue
 	L2Axis
 	R2Axis
+	// Values are stored in mappings, recordings and streams, new ones only ever go at the end
+	GuideButton // Xbox Guide / PlayStation PS
+	// DPad directions as buttons
+	DPadUpButton
+	DPadDownButton
+	DPadLeftButton
+	DPadRightButton
+	// Touchpad finger position, 0..MaxValue across the pad and negative while not touching
+	TouchpadXAxis
+	TouchpadYAxis
 )
 
+var resolvedNames = [...]string{
+	CrossButton:     "CrossButton",
+	CircleButton:    "CircleButton",
+	SquareButton:    "SquareButton",
+	TriangleButton:  "TriangleButton",
+	L1Button:        "L1Button",
+	R1Button:        "R1Button",
+	SelectButton:    "SelectButton",
+	StartButton:     "StartButton",
+	AnalogButton:    "AnalogButton",
+	LeftJoyButton:   "LeftJoyButton",
+	RightJoyButton:  "RightJoyButton",
+	DPadXAxis:       "DPadXAxis",
+	DPadYAxis:       "DPadYAxis",
+	LeftJoyXAxis:    "LeftJoyXAxis",
+	LeftJoyYAxis:    "LeftJoyYAxis",
+	RightJoyXAxis:   "RightJoyXAxis",
+	RightJoyYAxis:   "RightJoyYAxis",
+	L2Axis:          "L2Axis",
+	R2Axis:          "R2Axis",
+	GuideButton:     "GuideButton",
+	DPadUpButton:    "DPadUpButton",
+	DPadDownButton:  "DPadDownButton",
+	DPadLeftButton:  "DPadLeftButton",
+	DPadRightButton: "DPadRightButton",
+	TouchpadXAxis:   "TouchpadXAxis",
+	TouchpadYAxis:   "TouchpadYAxis",
+}
+
+func (r Resolved) String() string {
+	if r >= 0 && int(r) < len(resolvedNames) {
+		return resolvedNames[r]
+	}
+	return fmt.Sprintf("Resolved(%d)", int(r))
+}
+
+// IsAxis reports whether r is an axis rather than a button, L2 and R2 are axes
+func (r Resolved) IsAxis() bool {
+	switch r {
+	case DPadXAxis, DPadYAxis, LeftJoyXAxis, LeftJoyYAxis, RightJoyXAxis, RightJoyYAxis, L2Axis, R2Axis,
+		TouchpadXAxis, TouchpadYAxis:
+		return true
+	}
+	return false
+}
+
+// ParseResolved looks up a Resolved by its name, e.g. "CrossButton"
+func ParseResolved(name string) (Resolved, bool) {
+	for r, n := range resolvedNames {
+		if n == name {
+			return Resolved(r), true
+		}
+	}
+	return 0, false
+}
+
 const (
 	InputTypeButton int = iota
 	InputTypeAxis
@@ -44,31 +119,118 @@ type Input struct {
 
 type InputMapping map[Input]Resolved
 
+// Copy returns a mapping with the same entries that can be changed without affecting m
+func (m InputMapping) Copy() InputMapping {
+	if m == nil {
+		return nil
+	}
+	c := make(InputMapping, len(m))
+	for in, resolved := range m {
+		c[in] = resolved
+	}
+	return c
+}
+
+// AxisConfig describes an axis input fully, including how its raw value is interpreted
+type AxisConfig struct {
+	Target Resolved
+	Scale  float32 // Multiplier for the raw value, zero leaves it unscaled
+	Invert bool
+}
+
+// Apply scales and inverts a raw axis value, limiting the result to the int16 range
+func (c AxisConfig) Apply(v int) int {
+	if c.Scale != 0 {
+		v = int(float32(v) * c.Scale)
+	}
+	if c.Invert {
+		v = -v
+	}
+	if v > MaxValue {
+		v = MaxValue
+	}
+	if v < -MaxValue {
+		v = -MaxValue
+	}
+	return v
+}
+
+// AxisMapping holds axis inputs that need more than a target
+type AxisMapping map[Input]AxisConfig
+
+// Copy returns a mapping with the same entries that can be changed without affecting m
+func (m AxisMapping) Copy() AxisMapping {
+	if m == nil {
+		return nil
+	}
+	c := make(AxisMapping, len(m))
+	for in, cfg := range m {
+		c[in] = cfg
+	}
+	return c
+}
+
 type driverName string
 
+// xpadMapping is the layout of the Linux xpad driver, shared by XInput controllers
+var xpadMapping = InputMapping{
+	Input{InputTypeButton, 0}:  CrossButton,
+	Input{InputTypeButton, 1}:  CircleButton,
+	Input{InputTypeButton, 2}:  SquareButton,
+	Input{InputTypeButton, 3}:  TriangleButton,
+	Input{InputTypeButton, 4}:  L1Button,
+	Input{InputTypeButton, 5}:  R1Button,
+	Input{InputTypeButton, 6}:  SelectButton,
+	Input{InputTypeButton, 7}:  StartButton,
+	Input{InputTypeButton, 8}:  GuideButton,
+	Input{InputTypeButton, 9}:  LeftJoyButton,
+	Input{InputTypeButton, 10}: RightJoyButton,
+	Input{InputTypeAxis, 6}:    DPadXAxis,
+	Input{InputTypeAxis, 7}:    DPadYAxis,
+	Input{InputTypeAxis, 0}:    LeftJoyXAxis,
+	Input{InputTypeAxis, 1}:    LeftJoyYAxis,
+	Input{InputTypeAxis, 3}:    RightJoyXAxis,
+	Input{InputTypeAxis, 4}:    RightJoyYAxis,
+	Input{InputTypeAxis, 2}:    L2Axis,
+	Input{InputTypeAxis, 5}:    R2Axis,
+}
+
+// logitechDirectInputMapping is the layout of the Logitech F310 and F710 with the switch on the back set to D.
+// The triggers are digital buttons and the dpad is a hat reported on axes 4 and 5.
+var logitechDirectInputMapping = InputMapping{
+	Input{InputTypeButton, 0}:  SquareButton,
+	Input{InputTypeButton, 1}:  CrossButton,
+	Input{InputTypeButton, 2}:  CircleButton,
+	Input{InputTypeButton, 3}:  TriangleButton,
+	Input{InputTypeButton, 4}:  L1Button,
+	Input{InputTypeButton, 5}:  R1Button,
+	Input{InputTypeButton, 6}:  L2Axis,
+	Input{InputTypeButton, 7}:  R2Axis,
+	Input{InputTypeButton, 8}:  SelectButton,
+	Input{InputTypeButton, 9}:  StartButton,
+	Input{InputTypeButton, 10}: LeftJoyButton,
+	Input{InputTypeButton, 11}: RightJoyButton,
+	Input{InputTypeAxis, 4}:    DPadXAxis,
+	Input{InputTypeAxis, 5}:    DPadYAxis,
+	Input{InputTypeAxis, 0}:    LeftJoyXAxis,
+	Input{InputTypeAxis, 1}:    LeftJoyYAxis,
+	Input{InputTypeAxis, 2}:    RightJoyXAxis,
+	Input{InputTypeAxis, 3}:    RightJoyYAxis,
+}
+
+// DriverMapping is the layout of each known driver. Drivers sharing a layout each get a copy of it, so changing the
+// mapping of one leaves the others alone.
 var DriverMapping = map[driverName]InputMapping{
 	// Ubuntu 22.04 arm64
-	"Microsoft X-Box 360 pad": {
-		Input{InputTypeButton, 0}:  CrossButton,
-		Input{InputTypeButton, 1}:  CircleButton,
-		Input{InputTypeButton, 2}:  SquareButton,
-		Input{InputTypeButton, 3}:  TriangleButton,
-		Input{InputTypeButton, 4}:  L1Button,
-		Input{InputTypeButton, 5}:  R1Button,
-		Input{InputTypeButton, 6}:  SelectButton,
-		Input{InputTypeButton, 7}:  StartButton,
-		Input{InputTypeButton, 8}:  AnalogButton,
-		Input{InputTypeButton, 9}:  LeftJoyButton,
-		Input{InputTypeButton, 10}: RightJoyButton,
-		Input{InputTypeAxis, 6}:    DPadXAxis,
-		Input{InputTypeAxis, 7}:    DPadYAxis,
-		Input{InputTypeAxis, 0}:    LeftJoyXAxis,
-		Input{InputTypeAxis, 1}:    LeftJoyYAxis,
-		Input{InputTypeAxis, 3}:    RightJoyXAxis,
-		Input{InputTypeAxis, 4}:    RightJoyYAxis,
-		Input{InputTypeAxis, 2}:    L2Axis,
-		Input{InputTypeAxis, 5}:    R2Axis,
-	},
+	"Microsoft X-Box 360 pad": xpadMapping.Copy(),
+
+	// Logitech F310 and F710, switch on the back set to X (XInput)
+	"Logitech Gamepad F310": xpadMapping.Copy(),
+	"Logitech Gamepad F710": xpadMapping.Copy(),
+
+	// Logitech F310 and F710, switch on the back set to D (DirectInput)
+	"Logitech Logitech Dual Action": logitechDirectInputMapping.Copy(),
+	"Logitech Cordless RumblePad 2": logitechDirectInputMapping.Copy(),
 
 	// Raspberry pi 4 - Ubuntu 22.10
 	"SHANWAN Android Gamepad": {
@@ -103,7 +265,7 @@ var DriverMapping = map[driverName]InputMapping{
 		Input{InputTypeButton, 12}: R1Button,
 		Input{InputTypeButton, 2}:  SelectButton,
 		Input{InputTypeButton, 1}:  StartButton,
-		Input{InputTypeButton, 13}: AnalogButton,
+		Input{InputTypeButton, 13}: GuideButton,
 		Input{InputTypeButton, 3}:  LeftJoyButton,
 		Input{InputTypeButton, 4}:  RightJoyButton,
 		Input{InputTypeAxis, 5}:    DPadXAxis,
@@ -117,21 +279,205 @@ var DriverMapping = map[driverName]InputMapping{
 	},
 }
 
+// DisconnectReason is a best effort classification of why a device stopped delivering events
+type DisconnectReason int
+
+const (
+	NotDisconnected DisconnectReason = iota
+	// DisconnectEnded is a clean end of input, e.g. the end of a replay
+	DisconnectEnded
+	// DisconnectRemoved is the device going away, powered off or unplugged
+	DisconnectRemoved
+	// DisconnectIOError is any other read failure, e.g. a flaky cable or a Mac waking from sleep
+	DisconnectIOError
+)
+
+func (r DisconnectReason) String() string {
+	switch r {
+	case NotDisconnected:
+		return "NotDisconnected"
+	case DisconnectEnded:
+		return "Ended"
+	case DisconnectRemoved:
+		return "Removed"
+	case DisconnectIOError:
+		return "IOError"
+	}
+	return "Unknown"
+}
+
+// ErrReadTimeout is raised on the error channel when the device sends nothing within ConnectConfig.ReadTimeout
+var ErrReadTimeout = errors.New("device read timed out")
+
+// ConnectConfig tunes how the device is opened and read
+type ConnectConfig struct {
+	// ReadTimeout is how long a read may block before ErrReadTimeout is raised, zero waits forever
+	ReadTimeout time.Duration
+
+	// DisableKernelRepeat turns off kernel autorepeat for the device, only meaningful on Linux
+	DisableKernelRepeat bool
+
+	// AnyDevice accepts a device whose name has no DriverMapping entry, for callers bringing their own mapping
+	AnyDevice bool
+
+	// DevicePath reads joystick API events from this file instead of searching /dev/input, e.g. a FIFO a test writes into.
+	// Linux only. Counts, axis ranges, rumble and LEDs aren't available for it.
+	DevicePath string
+
+	// DevicePathDriver is the driver whose mapping a DevicePath uses, the X-Box 360 pad when empty
+	DevicePathDriver string
+
+	// ButtonThreshold is the fraction of full pressure, in 0..1, an analog button or trigger must reach to count as pressed.
+	// Zero keeps the defaults, any pressure presses an analog button and the 360 triggers press when fully pulled.
+	// Darwin only, the Linux joystick API reports buttons as pressed or not and analog controls as axes.
+	ButtonThreshold float32
+
+	// MinReportInterval thins axis events to at most one per axis every interval, keeping the latest value, to save CPU on
+	// slow boards with chatty controllers. Button events are never thinned. Zero delivers every event.
+	// Events are thinned once read and decoded, the reports themselves are all read.
+	MinReportInterval time.Duration
+
+	// DeviceID connects to the device with this ID from Devices, rather than the first gamepad found
+	DeviceID string
+}
+
+// DeviceInfo describes a connected gamepad, as listed by Devices
+type DeviceInfo struct {
+	// ID tells the device apart from others of the same kind, for ConnectConfig.DeviceID. It stays the same while the
+	// device is plugged in, the same controller can get another ID once plugged in again.
+	ID string
+
+	// Name is the driver the device is found as, on Darwin its USB vendor and product IDs
+	Name string
+}
+
+// devicePathDriver is the driver of a DevicePath without a DevicePathDriver
+const devicePathDriver driverName = "Microsoft X-Box 360 pad"
+
+// NameMatcher decides whether a device name belongs to a driver
+type NameMatcher func(name string) bool
+
+// DriverNameMatchers lets devices whose name differs slightly from a DriverMapping key, e.g. another revision, use that driver's mapping.
+// They're tried in order of driver name.
+// Exact names are tried first, a matcher is only consulted when none matches.
+var DriverNameMatchers = map[driverName]NameMatcher{}
+
+// matchDriver finds the driver for a device name, exact DriverMapping names first and then DriverNameMatchers.
+// With any, every name is its own driver.
+func matchDriver(name string, any bool) (driverName, bool) {
+	if any {
+		return driverName(name), true
+	}
+	for k := range DriverMapping {
+		if name == string(k) {
+			return k, true
+		}
+	}
+
+	// Map order is random, sorting makes a name that several matchers accept always get the same driver
+	drivers := make([]driverName, 0, len(DriverNameMatchers))
+	for k := range DriverNameMatchers {
+		drivers = append(drivers, k)
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i] < drivers[j] })
+	for _, k := range drivers {
+		if _, ok := DriverMapping[k]; ok && DriverNameMatchers[k](name) {
+			log.Printf("Device %q matched driver %q", name, k)
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// MatchPrefix matches names starting with prefix
+func MatchPrefix(prefix string) NameMatcher {
+	return func(name string) bool {
+		return strings.HasPrefix(name, prefix)
+	}
+}
+
+// MatchSubstring matches names containing s
+func MatchSubstring(s string) NameMatcher {
+	return func(name string) bool {
+		return strings.Contains(name, s)
+	}
+}
+
+// MatchRegexp matches names matching the regular expression expr, it panics if expr doesn't compile
+func MatchRegexp(expr string) NameMatcher {
+	re := regexp.MustCompile(expr)
+	return re.MatchString
+}
+
+// DriverAxisMapping optionally refines DriverMapping per driver, an entry here takes precedence for the same Input.
+// It's a table of its own, rather than DriverMapping values carrying an AxisConfig, so existing InputMapping literals
+// keep working. A gamepad given WithMapping doesn't use it, see WithAxisMapping.
+var DriverAxisMapping = map[driverName]AxisMapping{}
+
 type HID struct {
+	// Dropped event counts, first in the struct so they are 64-bit aligned for atomic access on 32-bit ARM
+	droppedButtons uint64
+	droppedAxes    uint64
+
 	ctx        context.Context
 	osEventsCh chan osEvent
-	buttonCh   chan buttonEvent
-	axisCh     chan axisEvent
+	buttonCh   chan RawButtonEvent
+	axisCh     chan RawAxisEvent
+	errCh      chan error
+	doneCh     chan struct{}
+	stopCh     chan struct{}
+	stopOnce   sync.Once
 	Driver     driverName
+	ID         string // Identifies the device among those connected, as listed by Devices
+
+	buttonCount int
+	axisCount   int
+	axisRanges  []AxisRange
+	rumble      bool
+
+	// buttonThreshold is ConnectConfig.ButtonThreshold
+	buttonThreshold float32
+
+	// reportInterval is ConnectConfig.MinReportInterval, set before the reader starts
+	reportInterval time.Duration
+
+	// epoch is the device timestamp events are measured from
+	epoch uint32
+
+	mu        sync.Mutex
+	recorder  *Recorder
+	binaryLog *BinaryLog
+
+	// stepCh releases events of a stepped replay, nil otherwise
+	stepCh chan struct{}
+
+	// output takes output reports such as rumble, nil when the device has no output path
+	output io.Writer
+
+	// ffID is the force feedback effect uploaded for rumble on Linux, -1 until the first upload
+	ffID int16
+
+	reason DisconnectReason
+
+	// playerLED is the sysfs brightness file of the xpad LED ring on Linux, empty without one
+	playerLED string
+
+	// lastDropLog rate limits the dropped event log
+	lastDropLog time.Time
 }
 
-type buttonEvent struct {
+// dropLogInterval is the least time between two dropped event log lines
+const dropLogInterval = 5 * time.Second
+
+// RawButtonEvent is a button event as the device reported it, before any mapping. When is the time since the device connected.
+type RawButtonEvent struct {
 	When   time.Duration
 	Button uint8
 	Value  int16
 }
 
-type axisEvent struct {
+// RawAxisEvent is an axis event as the device reported it, before any mapping or normalization
+type RawAxisEvent struct {
 	When  time.Duration
 	Axis  uint8
 	Value int16
@@ -149,8 +495,11 @@ func newHID(ctx context.Context) *HID {
 	h := &HID{
 		ctx:        ctx,
 		osEventsCh: make(chan osEvent),
-		buttonCh:   make(chan buttonEvent),
-		axisCh:     make(chan axisEvent),
+		buttonCh:   make(chan RawButtonEvent),
+		axisCh:     make(chan RawAxisEvent),
+		errCh:      make(chan error, 8),
+		doneCh:     make(chan struct{}),
+		stopCh:     make(chan struct{}),
 	}
 	go h.handleEvents()
 	return h
@@ -158,45 +507,210 @@ func newHID(ctx context.Context) *HID {
 
 // handleEvents waits on the HID.OSEvents channel (so is blocking), then puts any events matching onto any registered channel(s).
 func (h *HID) handleEvents() {
+	// Axis events held back by the report interval, the latest per axis in order of first arrival
+	var pending []osEvent
+	var flush <-chan time.Time
+	var lastAxis time.Time
+
 	for {
 		select {
 		case <-h.ctx.Done():
 			return
+		case <-flush:
+			h.deliverAll(pending)
+			pending, flush, lastAxis = pending[:0], nil, time.Now()
 		case evt, ok := <-h.osEventsCh:
 			if !ok {
+				h.deliverAll(pending)
+				h.mu.Lock()
+				rec, reason := h.recorder, h.reason
+				h.mu.Unlock()
+				if rec != nil {
+					rec.end(h.Driver, reason)
+				}
+				close(h.doneCh)
 				return
 			}
 
 			switch eventType(evt.Type) {
-			case buttonEventType:
-				select {
-				case h.buttonCh <- buttonEvent{
-					When:   toElapsed(evt.Time),
-					Button: evt.Index,
-					Value:  evt.Value,
-				}:
-				case <-time.NewTimer(time.Millisecond * 20).C:
-					log.Printf("Button event dropped, index: %v", evt.Index)
+			case buttonEventType, axisEventType:
+				h.mu.Lock()
+				rec, l := h.recorder, h.binaryLog
+				h.mu.Unlock()
+				if rec != nil {
+					rec.write(h.Driver, h.toElapsed(evt.Time), evt)
+				}
+				if l != nil {
+					l.logEvent(evt)
 				}
+			}
+
+			switch eventType(evt.Type) {
+			case buttonEventType:
+				// Buttons are never thinned, the axes before them go first to keep the order
+				h.deliverAll(pending)
+				pending, flush = pending[:0], nil
 			case axisEventType:
-				select {
-				case h.axisCh <- axisEvent{
-					When:  toElapsed(evt.Time),
-					Axis:  evt.Index,
-					Value: evt.Value,
-				}:
-				case <-time.NewTimer(time.Millisecond * 20).C:
-					log.Printf("Axis event dropped, index: %v", evt.Index)
+				if h.reportInterval > 0 && (flush != nil || time.Since(lastAxis) < h.reportInterval) {
+					pending = hold(pending, evt)
+					if flush == nil {
+						flush = time.After(h.reportInterval - time.Since(lastAxis))
+					}
+					continue
 				}
+				lastAxis = time.Now()
 			}
+			h.deliver(evt)
 		}
 	}
 }
 
-func (h *HID) OnButton() <-chan buttonEvent {
+// hold keeps evt as the latest pending value of its axis
+func hold(pending []osEvent, evt osEvent) []osEvent {
+	for i := range pending {
+		if pending[i].Index == evt.Index {
+			pending[i] = evt
+			return pending
+		}
+	}
+	return append(pending, evt)
+}
+
+func (h *HID) deliverAll(events []osEvent) {
+	for _, evt := range events {
+		h.deliver(evt)
+	}
+}
+
+// deliver puts an event on the button or axis channel, dropping it when nobody takes it in time
+func (h *HID) deliver(evt osEvent) {
+	when := h.toElapsed(evt.Time)
+	switch eventType(evt.Type) {
+	case buttonEventType:
+		select {
+		case h.buttonCh <- RawButtonEvent{
+			When:   when,
+			Button: evt.Index,
+			Value:  evt.Value,
+		}:
+		case <-time.NewTimer(time.Millisecond * 20).C:
+			atomic.AddUint64(&h.droppedButtons, 1)
+			h.logDropped()
+		}
+	case axisEventType:
+		select {
+		case h.axisCh <- RawAxisEvent{
+			When:  when,
+			Axis:  evt.Index,
+			Value: evt.Value,
+		}:
+		case <-time.NewTimer(time.Millisecond * 20).C:
+			atomic.AddUint64(&h.droppedAxes, 1)
+			h.logDropped()
+		}
+	}
+}
+
+// logDropped logs the dropped event counts, at most once per dropLogInterval. Only called by handleEvents.
+func (h *HID) logDropped() {
+	if time.Since(h.lastDropLog) < dropLogInterval {
+		return
+	}
+	h.lastDropLog = time.Now()
+	buttons, axes := h.DroppedEvents()
+	log.Printf("Events dropped, buttons: %v, axes: %v", buttons, axes)
+}
+
+// DroppedEvents is the number of button and axis events dropped because nobody was reading them in time
+func (h *HID) DroppedEvents() (buttons, axes uint64) {
+	return atomic.LoadUint64(&h.droppedButtons), atomic.LoadUint64(&h.droppedAxes)
+}
+
+func (h *HID) OnButton() <-chan RawButtonEvent {
 	return h.buttonCh
 }
 
-func (h *HID) OnAxis() <-chan axisEvent {
+func (h *HID) OnAxis() <-chan RawAxisEvent {
 	return h.axisCh
 }
+
+// toElapsed converts a device timestamp in milliseconds to the time since the epoch
+func (h *HID) toElapsed(m uint32) time.Duration {
+	return time.Duration(m-h.epoch) * time.Millisecond
+}
+
+// Disconnected is closed once the device stops delivering events
+func (h *HID) Disconnected() <-chan struct{} {
+	return h.doneCh
+}
+
+// StopInput stops reading the device, events already read are still delivered and then Disconnected fires with DisconnectEnded
+func (h *HID) StopInput() {
+	h.stopOnce.Do(func() { close(h.stopCh) })
+}
+
+// stopped reports whether StopInput was called
+func (h *HID) stopped() bool {
+	select {
+	case <-h.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// DisconnectReason is why the device stopped delivering events, NotDisconnected until Disconnected fires
+func (h *HID) DisconnectReason() DisconnectReason {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.reason
+}
+
+// disconnect records why reading stopped and ends the event stream
+func (h *HID) disconnect(reason DisconnectReason) {
+	h.mu.Lock()
+	h.reason = reason
+	h.mu.Unlock()
+	close(h.osEventsCh)
+}
+
+// OnError delivers errors raised while reading the device
+func (h *HID) OnError() <-chan error {
+	return h.errCh
+}
+
+// raise puts an error on the error channel without ever blocking the reader
+func (h *HID) raise(err error) {
+	select {
+	case h.errCh <- err:
+	default:
+		log.Printf("Error dropped, err: %v", err)
+	}
+}
+
+// ButtonCount is the number of buttons reported by the device, zero when the platform can't tell
+func (h *HID) ButtonCount() int {
+	return h.buttonCount
+}
+
+// HasRumble reports whether probing the device found force feedback support
+func (h *HID) HasRumble() bool {
+	return h.rumble
+}
+
+// AxisRange is the range an axis reports natively, as given by the Linux EVIOCGABS request.
+// The joystick API rescales values to ±MaxValue using Min, Max and Flat, so only Fuzz, the noise of the axis, is left to honor.
+type AxisRange struct {
+	Min, Max   int32
+	Fuzz, Flat int32
+}
+
+// AxisRanges returns the native range of each axis by input index, nil when the platform can't tell
+func (h *HID) AxisRanges() []AxisRange {
+	return h.axisRanges
+}
+
+// AxisCount is the number of axes reported by the device, zero when the platform can't tell
+func (h *HID) AxisCount() int {
+	return h.axisCount
+}
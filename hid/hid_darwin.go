@@ -3,47 +3,158 @@ package hid
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/google/gousb"
 	"log"
+	"strings"
+	"sync"
 	"time"
 )
 
 const MaxValue = 1<<15 - 1
 
-var firstTimestamp time.Time
+// YAxisUp is the sign of a y axis pushed physically up, the 360 report uses positive for up
+const YAxisUp = 1
 
-// Connect to device by index found in /dev/input/js*
-func Connect(c context.Context) (*HID, error) {
-	// Initialize a new Context.
-	ctx := gousb.NewContext()
+// usb is the libusb context shared by every connection, it is closed once the last connection is done with it
+var usb struct {
+	sync.Mutex
+	ctx  *gousb.Context
+	refs int
+}
+
+func acquireContext() *gousb.Context {
+	usb.Lock()
+	defer usb.Unlock()
+
+	if usb.refs == 0 {
+		usb.ctx = gousb.NewContext()
+	}
+	usb.refs++
+	return usb.ctx
+}
+
+func releaseContext() {
+	usb.Lock()
+	defer usb.Unlock()
+
+	usb.refs--
+	if usb.refs == 0 {
+		_ = usb.ctx.Close()
+		usb.ctx = nil
+	}
+}
+
+// isGamepad reports whether the device is one of USBDevices
+func isGamepad(desc *gousb.DeviceDesc) bool {
+	for _, d := range USBDevices {
+		if desc.Vendor == gousb.ID(d.Vendor) && desc.Product == gousb.ID(d.Product) {
+			return true
+		}
+	}
+	return false
+}
+
+// usbID identifies a device by where it's plugged in
+func usbID(desc *gousb.DeviceDesc) string {
+	return fmt.Sprintf("%v:%v", desc.Bus, desc.Address)
+}
+
+// Devices lists the gamepads on USB, their ID is the bus and address.
+// They aren't opened, so their Name is the vendor and product IDs rather than the driver they connect as.
+func Devices(config ConnectConfig) ([]DeviceInfo, error) {
+	ctx := acquireContext()
+	defer releaseContext()
 
-	// Open any device with a given VID/PID using a convenience function.
-	dev, err := ctx.OpenDeviceWithVIDPID(0x045e, 0x028e)
+	var devices []DeviceInfo
+	_, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if isGamepad(desc) {
+			devices = append(devices, DeviceInfo{ID: usbID(desc), Name: fmt.Sprintf("%v:%v", desc.Vendor, desc.Product)})
+		}
+		return false
+	})
+	return devices, err
+}
+
+// openDevice opens the first gamepad, or the one with the given ID
+func openDevice(ctx *gousb.Context, id string) (*gousb.Device, error) {
+	devs, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		return isGamepad(desc) && (id == "" || id == usbID(desc))
+	})
+	var dev *gousb.Device
+	for i, d := range devs {
+		if i == 0 {
+			dev = d
+			continue
+		}
+		_ = d.Close()
+	}
+	if dev == nil && err != nil {
+		return nil, err
+	}
+	return dev, nil
+}
+
+// Connect to the first of USBDevices found on USB, or to config.DeviceID
+func Connect(c context.Context, config ConnectConfig) (*HID, error) {
+	if config.DevicePath != "" {
+		return nil, fmt.Errorf("device path: %w", ErrUnsupported)
+	}
+
+	ctx := acquireContext()
+
+	dev, err := openDevice(ctx, config.DeviceID)
 	if err != nil {
+		releaseContext()
 		return nil, fmt.Errorf("could not open a device: %v", err)
 	}
+	if dev == nil {
+		releaseContext()
+		return nil, errors.New("could not open a device: not found")
+	}
 
 	log.Printf("Opened device: %v", dev)
 
 	// Switch the configuration to #1
 	cfg, err := dev.Config(1)
 	if err != nil {
+		_ = dev.Close()
+		releaseContext()
 		return nil, fmt.Errorf("invalid config number for device: %v", err)
 	}
 
 	intf, err := cfg.Interface(0, 0)
 	if err != nil {
+		_ = cfg.Close()
+		_ = dev.Close()
+		releaseContext()
 		return nil, fmt.Errorf("invalid interface number for device: %v", err)
 	}
 
 	in, err := intf.InEndpoint(1)
 	if err != nil {
+		intf.Close()
+		_ = cfg.Close()
+		_ = dev.Close()
+		releaseContext()
 		return nil, fmt.Errorf("invalid input endpoint for device: %v", err)
 	}
 
 	d := newHID(c)
-	d.Driver = "MacOS"
+	d.Driver = darwinDriver(dev, config.AnyDevice)
+	d.ID = usbID(dev.Desc)
+	d.buttonThreshold = config.ButtonThreshold
+	d.reportInterval = config.MinReportInterval
+
+	// Rumble is written to the out endpoint, a device without one can't rumble
+	if out, err := intf.OutEndpoint(1); err == nil {
+		d.output = out
+		d.rumble = true
+	}
+	if err := d.initOutput(); err != nil {
+		log.Printf("Error initializing output, err: %v", err)
+	}
 
 	// Clean up on context done
 	go func() {
@@ -51,19 +162,58 @@ func Connect(c context.Context) (*HID, error) {
 		intf.Close()
 		_ = cfg.Close()
 		_ = dev.Close()
-		_ = ctx.Close()
+		releaseContext()
 	}()
 
-	// Start reading from /dev/input device
-	go readDeviceInput(in, d.osEventsCh)
+	// Reads are cancelled on their own for StopInput, the device stays open until c is done
+	readCtx, stopRead := context.WithCancel(c)
+	go func() {
+		defer stopRead()
+		select {
+		case <-readCtx.Done():
+		case <-d.stopCh:
+		}
+	}()
 
-	// Read initial events from gamepad
-	firstTimestamp = time.Now()
+	// Start reading from the device, its event times count from now
+	go d.readDeviceInput(readCtx, in, config.ReadTimeout, time.Now())
 	return d, nil
 }
 
+// darwinDriver names the driver from the USB product string, as "<manufacturer> <product>" or the product alone, so devices
+// match DriverMapping like on Linux, see darwinDriverName.
+func darwinDriver(dev *gousb.Device, any bool) driverName {
+	product, err := dev.Product()
+	if err != nil || strings.TrimSpace(product) == "" {
+		return "MacOS"
+	}
+	product = strings.TrimSpace(product)
+
+	var names []string
+	if manufacturer, err := dev.Manufacturer(); err == nil && strings.TrimSpace(manufacturer) != "" {
+		names = append(names, strings.TrimSpace(manufacturer)+" "+product)
+	}
+	names = append(names, product)
+	return darwinDriverName(names, any)
+}
+
+// darwinDriverName picks the driver for the first of names matching a DriverMapping entry with a registered Decoder.
+// Other entries lay out the indices of a Linux driver, which the built-in 360 decoding doesn't report, so those devices
+// and ones matching nothing use "MacOS". With any, a device matching nothing keeps its name.
+func darwinDriverName(names []string, any bool) driverName {
+	for _, name := range names {
+		if k, ok := matchDriver(name, false); ok && decoderFor(k) != nil {
+			return k
+		}
+	}
+	if _, known := DriverMapping[driverName(names[0])]; any && !known {
+		return driverName(names[0])
+	}
+	return "MacOS"
+}
+
 type osEvent struct {
-	Time  uint32 // ms since firstTimestamp
+	Time  uint32 // ms since the device was opened
 	Value int16
 	Type  uint8
 	Index uint8
@@ -84,13 +234,24 @@ type cache struct {
 	tBtn      bool
 	l1Btn     bool
 	r1Btn     bool
-	analogBtn bool
+	guideBtn  bool
 	l2Axis    bool
 	r2Axis    bool
 	ljXAxis   bool
 	ljYAxis   bool
 	rjXAxis   bool
 	rjYAxis   bool
+
+	// threshold is the ConnectConfig.ButtonThreshold the triggers press at, zero presses them when fully pulled
+	threshold float32
+}
+
+// trigger maps an analog trigger byte to the 255 buttonEdge takes as pressed once it reaches the threshold
+func (c *cache) trigger(b byte) byte {
+	if c.threshold > 0 && float32(b) >= c.threshold*255 {
+		return 255
+	}
+	return b
 }
 
 func (c *cache) buttonEdge(b byte, want byte, cache *bool) (eventType, uint8) {
@@ -125,12 +286,23 @@ func (c *cache) axisEdge(val int16, cache *bool) (eventType, int16) {
 	return invalidEventType, 0
 }
 
-func emit(ch chan osEvent, eventType eventType, index uint8, value int16) {
+func decoded(eventType eventType, index uint8, value int16) DecodedInput {
+	in := DecodedInput{Type: InputTypeButton, Index: index, Value: value}
+	if eventType == axisEventType {
+		in.Type = InputTypeAxis
+	}
+	return in
+}
+
+func emit(ch chan osEvent, opened time.Time, in DecodedInput) {
 	ev := osEvent{
-		Time:  uint32(time.Since(firstTimestamp).Milliseconds()),
-		Value: value,
-		Type:  uint8(eventType),
-		Index: index,
+		Time:  uint32(time.Since(opened).Milliseconds()),
+		Value: in.Value,
+		Type:  uint8(buttonEventType),
+		Index: in.Index,
+	}
+	if in.Type == InputTypeAxis {
+		ev.Type = uint8(axisEventType)
 	}
 
 	ch <- ev
@@ -150,7 +322,7 @@ const (
 	triangleButtonIndex
 	l1ButtonIndex
 	r1ButtonIndex
-	analogButtonIndex
+	guideButtonIndex
 	l2AxisIndex
 	r2AxisIndex
 	ljxAxisIndex
@@ -159,167 +331,221 @@ const (
 	rjyAxisIndex
 )
 
-func readDeviceInput(in *gousb.InEndpoint, ch chan osEvent) {
-	c := cache{}
+// read performs a single transfer, bounded by timeout when set
+func read(ctx context.Context, in *gousb.InEndpoint, buf []byte, timeout time.Duration) (int, error) {
+	if timeout <= 0 {
+		return in.ReadContext(ctx, buf)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	n, err := in.ReadContext(ctx, buf)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return n, ErrReadTimeout
+	}
+	return n, err
+}
+
+// readDeviceInput reads the device's reports until ctx is done or reading fails, timing events from opened. Every
+// connection has its own, so a device opened later doesn't move the times of the others.
+func (h *HID) readDeviceInput(ctx context.Context, in *gousb.InEndpoint, timeout time.Duration, opened time.Time) {
+	ch := h.osEventsCh
+	c := cache{threshold: h.buttonThreshold}
 	buf := make([]byte, in.Desc.MaxPacketSize)
 	for {
 
-		readBytes, err := in.Read(buf)
+		readBytes, err := read(ctx, in, buf, timeout)
+		if errors.Is(err, ErrReadTimeout) {
+			h.raise(err)
+			continue
+		}
 		if err != nil {
-			log.Fatalf("Read error: %v", err)
+			if h.stopped() {
+				h.disconnect(DisconnectEnded)
+				return
+			}
+			// A transient libusb error, e.g. after sleep/wake, ends this device rather than the process.
+			// Disconnected fires so the gamepad can reconnect.
+			if ctx.Err() == nil {
+				h.raise(fmt.Errorf("read error: %w", err))
+			}
+			if errors.Is(err, gousb.ErrorNoDevice) || errors.Is(err, gousb.TransferNoDevice) {
+				h.disconnect(DisconnectRemoved)
+			} else {
+				h.disconnect(DisconnectIOError)
+			}
+			return
 		}
 
 		if readBytes == 0 {
-			log.Fatalf("Device returned 0 bytes of data.")
+			h.raise(errors.New("device returned 0 bytes of data"))
+			continue
 		}
+		h.logReport(buf[:readBytes])
 
-		// byte 2 MSB
-		b2msb := buf[2] >> 4
-		// Start = 1
-		if ev, v := c.buttonEdge(b2msb, 1, &c.startBtn); ev != invalidEventType {
-			emit(ch, ev, startButtonIndex, int16(v))
+		if dec := decoderFor(h.Driver); dec != nil {
+			for _, input := range dec.Decode(buf[:readBytes]) {
+				emit(ch, opened, h.applyThreshold(input))
+			}
+			continue
 		}
-
-		// Select = 2
-		if ev, v := c.buttonEdge(b2msb, 2, &c.selectBtn); ev != invalidEventType {
-			emit(ch, ev, selectButtonIndex, int16(v))
+		for _, input := range c.Decode(buf[:readBytes]) {
+			emit(ch, opened, input)
 		}
+	}
+}
 
-		// LJ = 4
-		if ev, v := c.buttonEdge(b2msb, 4, &c.ljBtn); ev != invalidEventType {
-			emit(ch, ev, ljButtonIndex, int16(v))
-		}
+// Decode is the built-in decoder for the Xbox 360 pad, turning changes in the input report into events
+func (c *cache) Decode(buf []byte) []DecodedInput {
+	// Only input reports (message type 0) carry the controls, LED and rumble status reports are shorter
+	if len(buf) < 14 || buf[0] != 0x00 {
+		return nil
+	}
 
-		// RJ = 8
-		if ev, v := c.buttonEdge(b2msb, 8, &c.rjBtn); ev != invalidEventType {
-			emit(ch, ev, rjButtonIndex, int16(v))
-		}
+	var out []DecodedInput
+	// byte 2 MSB
+	b2msb := buf[2] >> 4
+	// Start = 1
+	if ev, v := c.buttonEdge(b2msb, 1, &c.startBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, startButtonIndex, int16(v)))
+	}
 
-		// byte 3 - DPAD
-		b2lsb := buf[2] & 0xf
+	// Select = 2
+	if ev, v := c.buttonEdge(b2msb, 2, &c.selectBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, selectButtonIndex, int16(v)))
+	}
 
-		// Left
-		if ev, v := c.buttonEdge(b2lsb, 4, &c.lpadAxis); ev != invalidEventType {
-			if v == 1 {
-				emit(ch, axisEventType, dpadXAxisIndex, -MaxValue)
-			} else {
-				emit(ch, axisEventType, dpadXAxisIndex, 0)
-			}
-		}
+	// LJ = 4
+	if ev, v := c.buttonEdge(b2msb, 4, &c.ljBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, ljButtonIndex, int16(v)))
+	}
 
-		// Right
-		if ev, v := c.buttonEdge(b2lsb, 8, &c.rpadAxis); ev != invalidEventType {
-			if v == 1 {
-				emit(ch, axisEventType, dpadXAxisIndex, MaxValue)
-			} else {
-				emit(ch, axisEventType, dpadXAxisIndex, 0)
-			}
-		}
+	// RJ = 8
+	if ev, v := c.buttonEdge(b2msb, 8, &c.rjBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, rjButtonIndex, int16(v)))
+	}
 
-		// Up
-		if ev, v := c.buttonEdge(b2lsb, 1, &c.upadAxis); ev != invalidEventType {
-			if v == 1 {
-				emit(ch, axisEventType, dpadYAxisIndex, MaxValue)
-			} else {
-				emit(ch, axisEventType, dpadYAxisIndex, 0)
-			}
-		}
+	// byte 3 - DPAD
+	b2lsb := buf[2] & 0xf
 
-		// Down
-		if ev, v := c.buttonEdge(b2lsb, 2, &c.dpadAxis); ev != invalidEventType {
-			if v == 1 {
-				emit(ch, axisEventType, dpadYAxisIndex, -MaxValue)
-			} else {
-				emit(ch, axisEventType, dpadYAxisIndex, 0)
-			}
+	// Left
+	if ev, v := c.buttonEdge(b2lsb, 4, &c.lpadAxis); ev != invalidEventType {
+		if v == 1 {
+			out = append(out, decoded(axisEventType, dpadXAxisIndex, -MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, dpadXAxisIndex, 0))
 		}
+	}
 
-		// byte 4 MSB - Actions
-		b3msb := buf[3] >> 4
-		// X
-		if ev, v := c.buttonEdge(b3msb, 1, &c.xBtn); ev != invalidEventType {
-			emit(ch, ev, crossButtonIndex, int16(v))
+	// Right
+	if ev, v := c.buttonEdge(b2lsb, 8, &c.rpadAxis); ev != invalidEventType {
+		if v == 1 {
+			out = append(out, decoded(axisEventType, dpadXAxisIndex, MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, dpadXAxisIndex, 0))
 		}
+	}
 
-		// O
-		if ev, v := c.buttonEdge(b3msb, 2, &c.oBtn); ev != invalidEventType {
-			emit(ch, ev, circleButtonIndex, int16(v))
+	// Up
+	if ev, v := c.buttonEdge(b2lsb, 1, &c.upadAxis); ev != invalidEventType {
+		if v == 1 {
+			out = append(out, decoded(axisEventType, dpadYAxisIndex, MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, dpadYAxisIndex, 0))
 		}
+	}
 
-		// []
-		if ev, v := c.buttonEdge(b3msb, 4, &c.sBtn); ev != invalidEventType {
-			emit(ch, ev, squareButtonIndex, int16(v))
+	// Down
+	if ev, v := c.buttonEdge(b2lsb, 2, &c.dpadAxis); ev != invalidEventType {
+		if v == 1 {
+			out = append(out, decoded(axisEventType, dpadYAxisIndex, -MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, dpadYAxisIndex, 0))
 		}
+	}
 
-		// /\
-		if ev, v := c.buttonEdge(b3msb, 8, &c.tBtn); ev != invalidEventType {
-			emit(ch, ev, triangleButtonIndex, int16(v))
-		}
+	// byte 4 MSB - Actions
+	b3msb := buf[3] >> 4
+	// X
+	if ev, v := c.buttonEdge(b3msb, 1, &c.xBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, crossButtonIndex, int16(v)))
+	}
 
-		// byte 5 LSB - Top triggers + Analog
-		b3lsb := buf[3] & 0xf
-		// L1
-		if ev, v := c.buttonEdge(b3lsb, 1, &c.l1Btn); ev != invalidEventType {
-			emit(ch, ev, l1ButtonIndex, int16(v))
-		}
+	// O
+	if ev, v := c.buttonEdge(b3msb, 2, &c.oBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, circleButtonIndex, int16(v)))
+	}
 
-		// R1
-		if ev, v := c.buttonEdge(b3lsb, 2, &c.r1Btn); ev != invalidEventType {
-			emit(ch, ev, r1ButtonIndex, int16(v))
-		}
+	// []
+	if ev, v := c.buttonEdge(b3msb, 4, &c.sBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, squareButtonIndex, int16(v)))
+	}
 
-		// Analog
-		if ev, v := c.buttonEdge(b3lsb, 4, &c.analogBtn); ev != invalidEventType {
-			emit(ch, ev, analogButtonIndex, int16(v))
-		}
+	// /\
+	if ev, v := c.buttonEdge(b3msb, 8, &c.tBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, triangleButtonIndex, int16(v)))
+	}
 
-		// byte 4 - L2
-		b4 := buf[4]
-		if ev, v := c.buttonEdge(b4, 255, &c.l2Axis); ev != invalidEventType {
-			if v > 0 {
-				emit(ch, axisEventType, l2AxisIndex, MaxValue)
-			} else {
-				emit(ch, axisEventType, l2AxisIndex, 0)
-			}
-		}
+	// byte 5 LSB - Top triggers + Guide
+	b3lsb := buf[3] & 0xf
+	// L1
+	if ev, v := c.buttonEdge(b3lsb, 1, &c.l1Btn); ev != invalidEventType {
+		out = append(out, decoded(ev, l1ButtonIndex, int16(v)))
+	}
 
-		// byte 5 - R2
-		b5 := buf[5]
-		if ev, v := c.buttonEdge(b5, 255, &c.r2Axis); ev != invalidEventType {
-			if v > 0 {
-				emit(ch, axisEventType, r2AxisIndex, MaxValue)
-			} else {
-				emit(ch, axisEventType, r2AxisIndex, 0)
-			}
-		}
+	// R1
+	if ev, v := c.buttonEdge(b3lsb, 2, &c.r1Btn); ev != invalidEventType {
+		out = append(out, decoded(ev, r1ButtonIndex, int16(v)))
+	}
 
-		// byte 6 + 7
-		b67 := int16(binary.LittleEndian.Uint16(buf[6:8]))
-		if ev, v := c.axisEdge(b67, &c.ljXAxis); ev != invalidEventType {
-			emit(ch, ev, ljxAxisIndex, v)
-		}
+	// Guide
+	if ev, v := c.buttonEdge(b3lsb, 4, &c.guideBtn); ev != invalidEventType {
+		out = append(out, decoded(ev, guideButtonIndex, int16(v)))
+	}
 
-		// byte 8 + 9
-		b89 := int16(binary.LittleEndian.Uint16(buf[8:10]))
-		if ev, v := c.axisEdge(b89, &c.ljYAxis); ev != invalidEventType {
-			emit(ch, ev, ljyAxisIndex, v)
+	// byte 4 - L2
+	b4 := buf[4]
+	if ev, v := c.buttonEdge(c.trigger(b4), 255, &c.l2Axis); ev != invalidEventType {
+		if v > 0 {
+			out = append(out, decoded(axisEventType, l2AxisIndex, MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, l2AxisIndex, -MaxValue))
 		}
+	}
 
-		// byte 10 + 11
-		b1011 := int16(binary.LittleEndian.Uint16(buf[10:12]))
-		if ev, v := c.axisEdge(b1011, &c.rjXAxis); ev != invalidEventType {
-			emit(ch, ev, rjxAxisIndex, v)
+	// byte 5 - R2
+	b5 := buf[5]
+	if ev, v := c.buttonEdge(c.trigger(b5), 255, &c.r2Axis); ev != invalidEventType {
+		if v > 0 {
+			out = append(out, decoded(axisEventType, r2AxisIndex, MaxValue))
+		} else {
+			out = append(out, decoded(axisEventType, r2AxisIndex, -MaxValue))
 		}
+	}
 
-		// byte 11 + 12
-		b1213 := int16(binary.LittleEndian.Uint16(buf[12:14]))
-		if ev, v := c.axisEdge(b1213, &c.rjYAxis); ev != invalidEventType {
-			emit(ch, ev, rjyAxisIndex, v)
-		}
+	// byte 6 + 7
+	b67 := int16(binary.LittleEndian.Uint16(buf[6:8]))
+	if ev, v := c.axisEdge(b67, &c.ljXAxis); ev != invalidEventType {
+		out = append(out, decoded(ev, ljxAxisIndex, v))
+	}
+
+	// byte 8 + 9
+	b89 := int16(binary.LittleEndian.Uint16(buf[8:10]))
+	if ev, v := c.axisEdge(b89, &c.ljYAxis); ev != invalidEventType {
+		out = append(out, decoded(ev, ljyAxisIndex, v))
 	}
-}
 
-// Compat with Linux drivers, just return the already computed milliseconds
-func toElapsed(m uint32) time.Duration {
-	return time.Duration(m) * time.Millisecond
+	// byte 10 + 11
+	b1011 := int16(binary.LittleEndian.Uint16(buf[10:12]))
+	if ev, v := c.axisEdge(b1011, &c.rjXAxis); ev != invalidEventType {
+		out = append(out, decoded(ev, rjxAxisIndex, v))
+	}
+
+	// byte 11 + 12
+	b1213 := int16(binary.LittleEndian.Uint16(buf[12:14]))
+	if ev, v := c.axisEdge(b1213, &c.rjYAxis); ev != invalidEventType {
+		out = append(out, decoded(ev, rjyAxisIndex, v))
+	}
+	return out
 }
@@ -0,0 +1,49 @@
+package hid
+
+import "testing"
+
+func TestContextReleasedByLastConnection(t *testing.T) {
+	for i := 0; i < 3; i++ {
+		a := acquireContext()
+		b := acquireContext()
+		if a != b {
+			t.Fatal("connections got different contexts")
+		}
+
+		releaseContext()
+		usb.Lock()
+		refs, ctx := usb.refs, usb.ctx
+		usb.Unlock()
+		if refs != 1 || ctx == nil {
+			t.Fatalf("after releasing one of two, refs: %v, context open: %v, want 1 and true", refs, ctx != nil)
+		}
+
+		releaseContext()
+		usb.Lock()
+		refs, ctx = usb.refs, usb.ctx
+		usb.Unlock()
+		if refs != 0 || ctx != nil {
+			t.Fatalf("after releasing both, refs: %v, context open: %v, want 0 and false", refs, ctx != nil)
+		}
+	}
+}
+
+func TestDriverNeedsDecoder(t *testing.T) {
+	names := []string{"Logitech Logitech Gamepad F310", "Logitech Gamepad F310"}
+	if d := darwinDriverName(names, false); d != "MacOS" {
+		t.Errorf("XInput pad without a decoder got driver %q, want MacOS", d)
+	}
+	if d := darwinDriverName(names, true); d != "Logitech Logitech Gamepad F310" {
+		t.Errorf("XInput pad accepted as any device got driver %q, want its name", d)
+	}
+
+	RegisterDecoder("Logitech Gamepad F310", DecoderFunc(func([]byte) []DecodedInput { return nil }))
+	defer func() {
+		decoders.Lock()
+		delete(decoders.m, "Logitech Gamepad F310")
+		decoders.Unlock()
+	}()
+	if d := darwinDriverName(names, false); d != "Logitech Gamepad F310" {
+		t.Errorf("XInput pad with a decoder got driver %q, want Logitech Gamepad F310", d)
+	}
+}
@@ -1,6 +1,7 @@
 package hid
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -8,8 +9,11 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"time"
+	"unsafe"
 )
 
 type osEvent struct {
@@ -23,37 +27,179 @@ type osEvent struct {
 
 const MaxValue = 1<<15 - 1
 
-var lastTimestamp uint32
+// YAxisUp is the sign of a y axis pushed physically up, the joystick API reports up as negative
+const YAxisUp = -1
+
+// ioctl requests from linux/joystick.h
+const (
+	jsiocgaxes    = 0x80016a11 // JSIOCGAXES, _IOR('j', 0x11, __u8)
+	jsiocgbuttons = 0x80016a12 // JSIOCGBUTTONS, _IOR('j', 0x12, __u8)
+	eviocsrep     = 0x40084503 // EVIOCSREP, _IOW('E', 0x03, unsigned int[2])
+	jsiocgaxmap   = 0x80406a32 // JSIOCGAXMAP, _IOR('j', 0x32, __u8[ABS_CNT])
+	eviocgabs     = 0x80184540 // EVIOCGABS(abs), _IOR('E', 0x40 + abs, struct input_absinfo)
+	absCnt        = 0x40
+)
+
+// ioctl issues a request on f without switching the file back to blocking mode, as f.Fd() would
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var errno syscall.Errno
+	if err := rc.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg))
+	}); err != nil {
+		return err
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// eventNode finds the evdev node backing the joystick, which takes the requests the joystick API lacks
+func eventNode(idx int) (string, error) {
+	nodes, err := filepath.Glob(fmt.Sprintf("/sys/class/input/js%v/device/event*", idx))
+	if err != nil {
+		return "", err
+	}
+	if len(nodes) == 0 {
+		return "", errors.New("no event device found")
+	}
+	return filepath.Join("/dev/input", filepath.Base(nodes[0])), nil
+}
+
+// disableRepeat zeroes the autorepeat delay and period on the evdev node backing the joystick
+func disableRepeat(idx int) error {
+	node, err := eventNode(idx)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(node, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	rep := [2]uint32{0, 0} // delay, period
+	return ioctl(f, eviocsrep, unsafe.Pointer(&rep))
+}
+
+// readAxisRanges asks the evdev node for the range of each joystick axis, the joystick API's axis map gives the ABS code behind each
+func (h *HID) readAxisRanges(js *os.File, idx int) error {
+	var axmap [absCnt]uint8
+	if err := ioctl(js, jsiocgaxmap, unsafe.Pointer(&axmap)); err != nil {
+		return err
+	}
+
+	node, err := eventNode(idx)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(node)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n := h.axisCount
+	if n > absCnt {
+		n = absCnt
+	}
+	ranges := make([]AxisRange, n)
+	for i := range ranges {
+		var info struct {
+			Value, Min, Max, Fuzz, Flat, Resolution int32
+		}
+		if err := ioctl(f, eviocgabs+uintptr(axmap[i]), unsafe.Pointer(&info)); err != nil {
+			return err
+		}
+		ranges[i] = AxisRange{Min: info.Min, Max: info.Max, Fuzz: info.Fuzz, Flat: info.Flat}
+	}
+	h.axisRanges = ranges
+	return nil
+}
+
+// readCounts asks the joystick driver how many axes and buttons the device has
+func (h *HID) readCounts(f *os.File) {
+	var axes, buttons uint8
+	if err := ioctl(f, jsiocgaxes, unsafe.Pointer(&axes)); err != nil {
+		log.Printf("Error reading axis count, err: %v", err)
+	}
+	if err := ioctl(f, jsiocgbuttons, unsafe.Pointer(&buttons)); err != nil {
+		log.Printf("Error reading button count, err: %v", err)
+	}
+	h.axisCount = int(axes)
+	h.buttonCount = int(buttons)
+}
+
+// probeRumble checks the force feedback capabilities of the evdev node backing the joystick, any bit set means rumble is supported
+func probeRumble(idx int) bool {
+	d, err := os.ReadFile(fmt.Sprintf("/sys/class/input/js%v/device/capabilities/ff", idx))
+	if err != nil {
+		return false
+	}
+	for _, word := range strings.Fields(string(d)) {
+		if strings.Trim(word, "0") != "" {
+			return true
+		}
+	}
+	return false
+}
 
 func deviceExists(index int) bool {
-	_, err := os.Stat(fmt.Sprintf("/dev/input/js%v", index))
+	_, err := os.Stat(joystickPath(index))
 	return err == nil
 }
 
-func isGamepad(idx int) (driverName, bool) {
+func isGamepad(idx int, any bool) (driverName, bool) {
 	d, err := os.ReadFile(fmt.Sprintf("/sys/class/input/js%v/device/name", idx))
 	if err != nil {
 		log.Printf("Error checking device name, err: %v", err)
 		return "", false
 	}
-	name := strings.TrimSpace(string(d))
-	for k, _ := range DriverMapping {
-		if name == string(k) {
-			return k, true
+	return matchDriver(strings.TrimSpace(string(d)), any)
+}
+
+// maxJoysticks is how many /dev/input/js* devices are looked at
+const maxJoysticks = 5
+
+func joystickPath(idx int) string {
+	return fmt.Sprintf("/dev/input/js%v", idx)
+}
+
+// Devices lists the gamepads found in /dev/input/js*, their ID is the device path
+func Devices(cfg ConnectConfig) ([]DeviceInfo, error) {
+	var devices []DeviceInfo
+	for i := 0; i < maxJoysticks; i++ {
+		if !deviceExists(i) {
+			continue
+		}
+		if n, ok := isGamepad(i, cfg.AnyDevice); ok {
+			devices = append(devices, DeviceInfo{ID: joystickPath(i), Name: string(n)})
 		}
 	}
-	return "", false
+	return devices, nil
 }
 
-// Connect to device by index found in /dev/input/js*
-func Connect(ctx context.Context) (*HID, error) {
+// Connect to device by index found in /dev/input/js*, or to cfg.DevicePath
+func Connect(ctx context.Context, cfg ConnectConfig) (*HID, error) {
+	if cfg.DevicePath != "" {
+		return connectPath(ctx, cfg)
+	}
 
 	var driver driverName
 	deviceIndex := -1
-	for i := 0; i < 5; i++ {
+	for i := 0; i < maxJoysticks; i++ {
+		if cfg.DeviceID != "" && cfg.DeviceID != joystickPath(i) {
+			continue
+		}
 		exists := deviceExists(i)
 		if exists {
-			if n, ok := isGamepad(i); ok {
+			if n, ok := isGamepad(i, cfg.AnyDevice); ok {
 				driver = n
 				deviceIndex = i
 				break
@@ -64,58 +210,123 @@ func Connect(ctx context.Context) (*HID, error) {
 		return nil, errors.New("cannot find device")
 	}
 
-	r, e := os.OpenFile(fmt.Sprintf("/dev/input/js%v", deviceIndex), os.O_RDWR, 0)
+	r, e := os.OpenFile(joystickPath(deviceIndex), os.O_RDWR, 0)
 	if e != nil {
 		return nil, e
 	}
 	d := newHID(ctx)
 	d.Driver = driver
+	d.ID = joystickPath(deviceIndex)
+	d.reportInterval = cfg.MinReportInterval
+	d.readCounts(r)
+	if err := d.readAxisRanges(r, deviceIndex); err != nil {
+		log.Printf("Error reading axis ranges, err: %v", err)
+	}
+	d.rumble = probeRumble(deviceIndex)
+	d.playerLED = findPlayerLED(deviceIndex)
+	if d.rumble {
+		if err := d.openRumble(deviceIndex); err != nil {
+			log.Printf("Error opening rumble, err: %v", err)
+		}
+	}
 
+	if cfg.DisableKernelRepeat {
+		if err := disableRepeat(deviceIndex); err != nil {
+			log.Printf("Error disabling kernel repeat, err: %v", err)
+		}
+	}
+
+	d.start(ctx, r, cfg.ReadTimeout)
+	return d, nil
+}
+
+// connectPath reads events from a file in the joystick API format, opening a FIFO blocks until a writer opens it.
+// The file isn't a real device, so only the event stream is set up.
+func connectPath(ctx context.Context, cfg ConnectConfig) (*HID, error) {
+	r, err := os.OpenFile(cfg.DevicePath, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	d := newHID(ctx)
+	d.ID = cfg.DevicePath
+	d.Driver = driverName(cfg.DevicePathDriver)
+	if d.Driver == "" {
+		d.Driver = devicePathDriver
+	}
+	d.reportInterval = cfg.MinReportInterval
+	d.start(ctx, r, cfg.ReadTimeout)
+	return d, nil
+}
+
+// start reads events from r until ctx is done, after taking in the initial state events
+func (h *HID) start(ctx context.Context, r *os.File, timeout time.Duration) {
 	// Clean up on context done
 	go func() {
 		<-ctx.Done()
 		_ = r.Close()
+		h.mu.Lock()
+		if f, ok := h.output.(*os.File); ok {
+			_ = f.Close()
+		}
+		h.mu.Unlock()
 	}()
 
-	// Start reading from /dev/input device
-	go readDeviceInput(r, d.osEventsCh)
+	// Closing the device ends the read, for StopInput
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.stopCh:
+			_ = r.Close()
+		}
+	}()
 
-	// Read initial events from gamepad
-	d.mapInitalEvents()
-	return d, nil
+	// Start reading from /dev/input device
+	go h.readDeviceInput(r, timeout)
 }
 
-func (h *HID) mapInitalEvents() {
-	for {
-		evt, ok := <-h.osEventsCh
-		if !ok {
-			return
-		}
-
-		switch evt.Type {
-		case 0x81:
-			lastTimestamp = evt.Time
-		case 0x82:
-			lastTimestamp = evt.Time
-		default:
-			// Receiving the first non 0x81 or 0x82 event is our signal that populating is done. Forward this event as a real event.
-			go func() { h.osEventsCh <- evt }()
-			return
-		}
+// mapInitalEvent takes the epoch from the initial state events (0x81, 0x82) the driver sends on open, reporting whether evt
+// was one. It's called by the reader, so the events after it are measured from the epoch without racing handleEvents.
+func (h *HID) mapInitalEvent(evt osEvent) bool {
+	switch evt.Type {
+	case 0x81, 0x82:
+		h.epoch = evt.Time
+		return true
 	}
+	return false
 }
 
-func readDeviceInput(r io.Reader, c chan osEvent) {
+func (h *HID) readDeviceInput(f *os.File, timeout time.Duration) {
 	var evt osEvent
 	for {
-		if binary.Read(r, binary.LittleEndian, &evt) != nil {
-			close(c)
+		if timeout > 0 {
+			_ = f.SetReadDeadline(time.Now().Add(timeout))
+		}
+
+		if err := binary.Read(f, binary.LittleEndian, &evt); err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				h.raise(ErrReadTimeout)
+				continue
+			}
+			switch {
+			case h.stopped(), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+				h.disconnect(DisconnectEnded)
+			case errors.Is(err, syscall.ENODEV):
+				h.disconnect(DisconnectRemoved)
+			default:
+				h.disconnect(DisconnectIOError)
+			}
 			return
 		}
-		c <- evt
+		if h.logged() != nil {
+			// The event is the report, re-encoded as read
+			var report bytes.Buffer
+			_ = binary.Write(&report, binary.LittleEndian, evt)
+			h.logReport(report.Bytes())
+		}
+		if h.mapInitalEvent(evt) {
+			continue
+		}
+		h.osEventsCh <- evt
 	}
 }
-
-func toElapsed(m uint32) time.Duration {
-	return time.Duration(m-lastTimestamp) * time.Millisecond
-}
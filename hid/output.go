@@ -0,0 +1,59 @@
+package hid
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnsupported is returned by optional features, such as rumble, that the controller or platform doesn't support.
+// Check for it with errors.Is, the README lists support per driver.
+var ErrUnsupported = errors.New("not supported by this device")
+
+// ErrNoOutput is returned when writing output to a device that has no output path
+var ErrNoOutput = fmt.Errorf("device has no output: %w", ErrUnsupported)
+
+// outputHandshakes lists, per driver, the reports that must be written after connecting before the device accepts
+// output such as rumble or LEDs. Controllers like the DS4 and DualSense need one, the Xbox 360 pad doesn't.
+var outputHandshakes = struct {
+	sync.Mutex
+	m map[driverName][][]byte
+}{m: make(map[driverName][][]byte)}
+
+// RegisterOutputHandshake makes backends writing raw reports send reports, in order, to devices of the driver once
+// connected, before any other output. It goes with a decoder from RegisterDecoder for a controller such as the DS4.
+// On Linux the kernel driver does any handshake itself, so only Darwin uses it.
+func RegisterOutputHandshake(driver driverName, reports ...[]byte) {
+	outputHandshakes.Lock()
+	defer outputHandshakes.Unlock()
+	outputHandshakes.m[driver] = reports
+}
+
+// initOutput sends the driver's handshake, if any, so later output reports take effect
+func (h *HID) initOutput() error {
+	if h.output == nil {
+		return nil
+	}
+
+	outputHandshakes.Lock()
+	reports := outputHandshakes.m[h.Driver]
+	outputHandshakes.Unlock()
+	for i, report := range reports {
+		if err := h.writeOutput(report); err != nil {
+			return fmt.Errorf("output handshake report %v: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// writeOutput writes one output report to the device
+func (h *HID) writeOutput(report []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.output == nil {
+		return ErrNoOutput
+	}
+	_, err := h.output.Write(report)
+	return err
+}
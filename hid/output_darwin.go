@@ -0,0 +1,16 @@
+package hid
+
+// SetPlayerLED lights the player indicator for player n, 1..4. The Xbox 360 LED report takes patterns 6..9 to turn on
+// the ring quadrant of player 1..4.
+func (h *HID) SetPlayerLED(n int) error {
+	if n < 1 || n > 4 {
+		return ErrUnsupported
+	}
+	return h.writeOutput([]byte{0x01, 0x03, byte(5 + n)})
+}
+
+// SetRumble sets the strong (low frequency) and weak (high frequency) motors, each 0..65535.
+// The Xbox 360 pad takes the top byte of each in bytes 3 and 4 of an 8 byte output report.
+func (h *HID) SetRumble(strong, weak uint16) error {
+	return h.writeOutput([]byte{0x00, 0x08, 0x00, byte(strong >> 8), byte(weak >> 8), 0x00, 0x00, 0x00})
+}
@@ -0,0 +1,116 @@
+package hid
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Force feedback from linux/input.h, rumble goes through the evdev node as the joystick API has no output
+const (
+	evFF     = 0x15
+	ffRumble = 0x50
+)
+
+// ffPeriodic mirrors the largest member of the ff_effect union, so ffEffect gets the kernel's size and alignment
+type ffPeriodic struct {
+	Waveform   uint16
+	Period     uint16
+	Magnitude  int16
+	Offset     int16
+	Phase      uint16
+	Envelope   [4]uint16
+	CustomLen  uint32
+	CustomData uintptr
+}
+
+// ffEffect mirrors struct ff_effect
+type ffEffect struct {
+	Type      uint16
+	ID        int16
+	Direction uint16
+	Trigger   [2]uint16
+	Replay    [2]uint16 // length, delay. A zero length plays until replaced.
+	U         ffPeriodic
+}
+
+// eviocsff is EVIOCSFF, _IOW('E', 0x80, struct ff_effect)
+const eviocsff = 0x40000000 | unsafe.Sizeof(ffEffect{})<<16 | 'E'<<8 | 0x80
+
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// findPlayerLED looks for the LED ring the xpad driver registers for the joystick device idx
+func findPlayerLED(idx int) string {
+	return playerLED(fmt.Sprintf("/sys/class/input/js%v", idx))
+}
+
+// playerLED finds the brightness of the xpad LED ring from the joystick's sysfs directory. The input device's parent is
+// the USB interface, while xpad registers the LED on the USB device above it.
+func playerLED(js string) string {
+	intf, err := filepath.EvalSymlinks(filepath.Join(js, "device", "device"))
+	if err != nil {
+		return ""
+	}
+	leds, err := filepath.Glob(filepath.Join(filepath.Dir(intf), "leds", "xpad*", "brightness"))
+	if err != nil || len(leds) == 0 {
+		return ""
+	}
+	return leds[0]
+}
+
+// SetPlayerLED lights the player indicator for player n, 1..4. The xpad LED ring takes the 360 LED command as its brightness,
+// 6..9 turn on the quadrant of player 1..4.
+func (h *HID) SetPlayerLED(n int) error {
+	if h.playerLED == "" || n < 1 || n > 4 {
+		return ErrUnsupported
+	}
+	return os.WriteFile(h.playerLED, []byte(strconv.Itoa(5+n)), 0)
+}
+
+func (h *HID) openRumble(idx int) error {
+	node, err := eventNode(idx)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(node, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.output = f
+	h.ffID = -1
+	return nil
+}
+
+// SetRumble sets the strong (low frequency) and weak (high frequency) motors, each 0..65535.
+// On Linux they are the magnitudes of an FF_RUMBLE effect, uploaded once and updated in place.
+func (h *HID) SetRumble(strong, weak uint16) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	f, ok := h.output.(*os.File)
+	if !ok {
+		return ErrNoOutput
+	}
+
+	effect := ffEffect{Type: ffRumble, ID: h.ffID}
+	magnitudes := (*[2]uint16)(unsafe.Pointer(&effect.U))
+	magnitudes[0], magnitudes[1] = strong, weak
+	if err := ioctl(f, eviocsff, unsafe.Pointer(&effect)); err != nil {
+		return err
+	}
+	h.ffID = effect.ID
+
+	return binary.Write(f, binary.LittleEndian, inputEvent{Type: evFF, Code: uint16(effect.ID), Value: 1})
+}
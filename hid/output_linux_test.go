@@ -0,0 +1,39 @@
+package hid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPlayerLEDOnUSBDevice(t *testing.T) {
+	// The layout of sysfs for an xpad pad: js0 -> input5 -> interface 1-1:1.0 of USB device 1-1, which has the LED
+	root := t.TempDir()
+	usb := filepath.Join(root, "devices", "usb1", "1-1")
+	intf := filepath.Join(usb, "1-1:1.0")
+	input := filepath.Join(intf, "input", "input5")
+	js := filepath.Join(input, "js0")
+	brightness := filepath.Join(usb, "leds", "xpad0", "brightness")
+	for _, dir := range []string{js, filepath.Dir(brightness)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(brightness, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		filepath.Join(input, "device"): intf,
+		filepath.Join(js, "device"):    input,
+		filepath.Join(root, "js0"):     js,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := playerLED(filepath.Join(root, "js0")); got != brightness {
+		t.Errorf("got %q, want %q", got, brightness)
+	}
+}
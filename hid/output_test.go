@@ -0,0 +1,40 @@
+package hid
+
+import (
+	"bytes"
+	"testing"
+)
+
+// reportWriter keeps every report written, one per Write
+type reportWriter struct {
+	reports [][]byte
+}
+
+func (w *reportWriter) Write(p []byte) (int, error) {
+	w.reports = append(w.reports, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestInitOutputSendsHandshake(t *testing.T) {
+	want := [][]byte{{0x05, 0xff}, {0x11, 0xc0, 0x20}}
+	RegisterOutputHandshake("handshake test", want...)
+	defer func() {
+		outputHandshakes.Lock()
+		delete(outputHandshakes.m, "handshake test")
+		outputHandshakes.Unlock()
+	}()
+
+	w := &reportWriter{}
+	h := &HID{Driver: "handshake test", output: w}
+	if err := h.initOutput(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.reports) != len(want) {
+		t.Fatalf("got %v reports, want %v", len(w.reports), len(want))
+	}
+	for i := range want {
+		if !bytes.Equal(w.reports[i], want[i]) {
+			t.Errorf("report %v is %x, want %x", i, w.reports[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package hid
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Recording format, all little-endian:
+//
+//	header: magic "PGRC", version uint8, driver name length uint8, driver name
+//	record: delta uint32 (ms since the previous record), type uint8, index uint8, value int16
+//
+// Storing deltas rather than timestamps makes a recording independent of the process that made it.
+// Each device connected while recording gets a header of its own, from version 2 its records end with a record of type
+// zero whose value is the DisconnectReason, and the next device's header follows.
+const (
+	recordingMagic   = "PGRC"
+	recordingVersion = 2
+)
+
+// Replay speeds outside this range are clamped
+const (
+	minReplaySpeed = 0.1
+	maxReplaySpeed = 10
+)
+
+// ReplayConfig tunes how a recording is played back
+type ReplayConfig struct {
+	// Speed scales playback, 2 plays twice as fast and 0.5 at half speed. Zero plays at the recorded speed.
+	Speed float64
+
+	// Stepped ignores the recorded timing, each event waits for a call to Step
+	Stepped bool
+}
+
+type record struct {
+	Delta uint32
+	Type  uint8
+	Index uint8
+	Value int16
+}
+
+// Recorder writes device events as a replayable stream, it can outlive a single HID so reconnects keep recording.
+// Every device is recorded under its own driver, a replay moves on to the next one as it reconnects.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	started bool
+	last    time.Duration
+	err     error
+}
+
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{w: w}
+}
+
+// Record writes every button and axis event of the device to rec
+func (h *HID) Record(rec *Recorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recorder = rec
+}
+
+func (r *Recorder) write(driver driverName, when time.Duration, evt osEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+
+	if !r.started {
+		r.started = true
+		if r.err = writeHeader(r.w, driver); r.err != nil {
+			log.Printf("Recording stopped, err: %v", r.err)
+			return
+		}
+	}
+
+	// A device restarts its clock, never write a negative delta
+	delta := when - r.last
+	if delta < 0 {
+		delta = 0
+	}
+	r.last = when
+
+	r.err = binary.Write(r.w, binary.LittleEndian, record{
+		Delta: uint32(delta.Milliseconds()),
+		Type:  evt.Type,
+		Index: evt.Index,
+		Value: evt.Value,
+	})
+	if r.err != nil {
+		log.Printf("Recording stopped, err: %v", r.err)
+	}
+}
+
+// end writes the end of the device's records, the next event starts a new device
+func (r *Recorder) end(driver driverName, reason DisconnectReason) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	if !r.started {
+		if r.err = writeHeader(r.w, driver); r.err != nil {
+			log.Printf("Recording stopped, err: %v", r.err)
+			return
+		}
+	}
+	r.started = false
+	r.last = 0
+
+	r.err = binary.Write(r.w, binary.LittleEndian, record{Type: uint8(invalidEventType), Value: int16(reason)})
+	if r.err != nil {
+		log.Printf("Recording stopped, err: %v", r.err)
+	}
+}
+
+func writeHeader(w io.Writer, driver driverName) error {
+	if len(driver) > 255 {
+		return errors.New("driver name too long")
+	}
+	header := append([]byte(recordingMagic), recordingVersion, uint8(len(driver)))
+	_, err := w.Write(append(header, driver...))
+	return err
+}
+
+func readHeader(r io.Reader) (driverName, error) {
+	header := make([]byte, len(recordingMagic)+2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", err
+	}
+	if string(header[:len(recordingMagic)]) != recordingMagic {
+		return "", errors.New("not a recording")
+	}
+	if v := header[len(recordingMagic)]; v < 1 || v > recordingVersion {
+		return "", fmt.Errorf("unsupported recording version: %v", v)
+	}
+
+	name := make([]byte, header[len(recordingMagic)+1])
+	if _, err := io.ReadFull(r, name); err != nil {
+		return "", err
+	}
+	return driverName(name), nil
+}
+
+// Replay creates a HID playing back a recording made by a Recorder, reproducing the recorded timing scaled by cfg.Speed.
+// Disconnected fires once the recorded device disconnected, with its reason, or once the recording ends. Calling Replay
+// again on r then plays the next device, io.EOF is returned when there is none.
+func Replay(ctx context.Context, r io.Reader, cfg ReplayConfig) (*HID, error) {
+	driver, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	speed := cfg.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	if speed < minReplaySpeed {
+		speed = minReplaySpeed
+	}
+	if speed > maxReplaySpeed {
+		speed = maxReplaySpeed
+	}
+
+	d := newHID(ctx)
+	d.Driver = driver
+	if cfg.Stepped {
+		d.stepCh = make(chan struct{})
+	}
+	go d.replay(r, speed)
+	return d, nil
+}
+
+// Step releases the next event of a stepped replay, reporting false once the recording has ended.
+// It does nothing for a device that isn't a stepped replay.
+func (h *HID) Step() bool {
+	if h.stepCh == nil {
+		return false
+	}
+
+	select {
+	case h.stepCh <- struct{}{}:
+		return true
+	case <-h.doneCh:
+		return false
+	case <-h.ctx.Done():
+		return false
+	}
+}
+
+// wait holds back the next event, either for its scaled delay or until Step is called
+func (h *HID) wait(delta uint32, speed float64) bool {
+	var next <-chan time.Time
+	var step <-chan struct{}
+	if h.stepCh != nil {
+		step = h.stepCh
+	} else {
+		next = time.After(time.Duration(float64(delta) / speed * float64(time.Millisecond)))
+	}
+
+	select {
+	case <-h.ctx.Done():
+		return false
+	case <-h.stopCh:
+		return false
+	case <-next:
+	case <-step:
+	}
+	return true
+}
+
+func (h *HID) replay(r io.Reader, speed float64) {
+	reason := DisconnectEnded
+	defer func() { h.disconnect(reason) }()
+
+	// The epoch is zero, so elapsed rebuilds the When of the original run
+	var elapsed uint32
+	for {
+		var rec record
+		if err := binary.Read(r, binary.LittleEndian, &rec); err != nil {
+			if !errors.Is(err, io.EOF) {
+				h.raise(err)
+				reason = DisconnectIOError
+			}
+			return
+		}
+
+		// The device's end isn't an event, a stepped replay doesn't wait for it
+		if eventType(rec.Type) == invalidEventType {
+			reason = DisconnectReason(rec.Value)
+			return
+		}
+
+		if !h.wait(rec.Delta, speed) {
+			return
+		}
+
+		elapsed += rec.Delta
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-h.stopCh:
+			return
+		case h.osEventsCh <- osEvent{
+			Time:  elapsed,
+			Value: rec.Value,
+			Type:  rec.Type,
+			Index: rec.Index,
+		}:
+		}
+	}
+}
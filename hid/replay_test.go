@@ -0,0 +1,46 @@
+package hid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestReplayPlaysEachRecordedDevice(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	rec.write("first", 0, osEvent{Type: uint8(buttonEventType), Index: 1, Value: 1})
+	rec.end("first", DisconnectRemoved)
+	rec.write("second", 0, osEvent{Type: uint8(buttonEventType), Index: 2, Value: 1})
+	rec.end("second", DisconnectEnded)
+
+	for _, want := range []struct {
+		driver driverName
+		button uint8
+		reason DisconnectReason
+	}{
+		{"first", 1, DisconnectRemoved},
+		{"second", 2, DisconnectEnded},
+	} {
+		d, err := Replay(context.Background(), &buf, ReplayConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Driver != want.driver {
+			t.Errorf("got driver %q, want %q", d.Driver, want.driver)
+		}
+		if e := <-d.OnButton(); e.Button != want.button {
+			t.Errorf("got button %v, want %v", e.Button, want.button)
+		}
+		<-d.Disconnected()
+		if reason := d.DisconnectReason(); reason != want.reason {
+			t.Errorf("got reason %v, want %v", reason, want.reason)
+		}
+	}
+
+	if _, err := Replay(context.Background(), &buf, ReplayConfig{}); !errors.Is(err, io.EOF) {
+		t.Errorf("replay past the last device got %v, want io.EOF", err)
+	}
+}
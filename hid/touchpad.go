@@ -0,0 +1,41 @@
+package hid
+
+// Offsets of the first touch point in the USB input report 1 of the DS4 and DualSense, report ID included.
+// A touch point is 4 bytes: bit 7 of the first set while not touching, then 12 bits of x and 12 bits of y.
+const (
+	ds4TouchOffset       = 35
+	dualSenseTouchOffset = 33
+)
+
+// DS4Touch decodes the first finger on the DS4 touchpad, 1920 by 942 units, from its USB input report as the values of
+// TouchpadXAxis and TouchpadYAxis, for a Decoder to report. ok is false for another report.
+func DS4Touch(report []byte) (x, y int16, ok bool) {
+	return touchPoint(report, ds4TouchOffset, 1920, 942)
+}
+
+// DualSenseTouch decodes the first finger on the DualSense touchpad, 1920 by 1080 units, like DS4Touch
+func DualSenseTouch(report []byte) (x, y int16, ok bool) {
+	return touchPoint(report, dualSenseTouchOffset, 1920, 1080)
+}
+
+// touchPoint scales the touch point at offset to 0..MaxValue across a pad of width by height units, -1 while not touching
+func touchPoint(report []byte, offset, width, height int) (int16, int16, bool) {
+	if len(report) < offset+4 || report[0] != 0x01 {
+		return 0, 0, false
+	}
+
+	p := report[offset : offset+4]
+	if p[0]&0x80 != 0 {
+		return -1, -1, true
+	}
+	x := int(p[1]) | int(p[2]&0x0f)<<8
+	y := int(p[2])>>4 | int(p[3])<<4
+	return scaleTouch(x, width), scaleTouch(y, height), true
+}
+
+func scaleTouch(v, units int) int16 {
+	if v >= units {
+		v = units - 1
+	}
+	return int16(v * MaxValue / (units - 1))
+}
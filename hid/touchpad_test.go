@@ -0,0 +1,28 @@
+package hid
+
+import "testing"
+
+func TestTouchPoint(t *testing.T) {
+	report := make([]byte, 64)
+	report[0] = 0x01
+	// x 1919 and y 471 on the DS4, the right edge halfway down
+	copy(report[ds4TouchOffset:], []byte{0x05, 0x7f, 0x77, 0x1d})
+
+	x, y, ok := DS4Touch(report)
+	if !ok || x != MaxValue || y != 471*MaxValue/941 {
+		t.Errorf("got %v, %v, %v, want %v, %v, true", x, y, ok, MaxValue, 471*MaxValue/941)
+	}
+
+	report[ds4TouchOffset] |= 0x80
+	if x, y, ok := DS4Touch(report); !ok || x != -1 || y != -1 {
+		t.Errorf("lifted finger got %v, %v, %v, want -1, -1, true", x, y, ok)
+	}
+
+	report[0] = 0x05
+	if _, _, ok := DS4Touch(report); ok {
+		t.Error("decoded a report other than input report 1")
+	}
+	if _, _, ok := DualSenseTouch(report[:20]); ok {
+		t.Error("decoded a short report")
+	}
+}
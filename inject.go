@@ -0,0 +1,60 @@
+package gamepad
+
+import (
+	"fmt"
+	. "github.com/gooseclip/pi-gamepad/hid"
+)
+
+// Inject delivers a button event to the registered handlers as if the device had produced it, bypassing the hardware and
+// the click/hold state machine. It's meant for testing handler wiring in applications, the button's tracked state is untouched.
+func (g *Gamepad) Inject(b Resolved, e ButtonEvent) error {
+	ref := g.buttonRef(b)
+	if ref == nil {
+		return fmt.Errorf("not a button: %v", b)
+	}
+
+	g.mu.Lock()
+	btn := *ref
+	g.mu.Unlock()
+
+	g.fire(b, btn, e)
+	return nil
+}
+
+// InjectAxis delivers already normalized axis values to the registered handlers of a group, bypassing the hardware.
+// It's meant for testing, no inversion, deadzone or rotation is applied. Triggers take their value from x.
+// Nothing is delivered while paused, like Inject.
+func (g *Gamepad) InjectAxis(group AxisGroup, x, y float32) {
+	if g.Paused() {
+		return
+	}
+	g.publish(InputEvent{Kind: AxisInput, Group: group, X: x, Y: y})
+	if g.axisHandler != nil {
+		g.axisHandler(group, x, y)
+	}
+
+	var handler directionHandler
+	var handler64 directionHandler64
+	switch group {
+	case DPadGroup:
+		handler, handler64 = g.dpadHandler, g.dpadHandler64
+	case LeftStickGroup:
+		handler, handler64 = g.leftJoyHandler, g.leftJoyHandler64
+	case RightStickGroup:
+		handler, handler64 = g.rightJoyHandler, g.rightJoyHandler64
+	case LeftTriggerGroup:
+		if g.l2AxisHandler != nil {
+			g.l2AxisHandler(x)
+		}
+	case RightTriggerGroup:
+		if g.r2AxisHandler != nil {
+			g.r2AxisHandler(x)
+		}
+	}
+	if handler != nil {
+		handler(x, y)
+	}
+	if handler64 != nil {
+		handler64(float64(x), float64(y))
+	}
+}
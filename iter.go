@@ -0,0 +1,31 @@
+//go:build go1.23
+
+package gamepad
+
+import (
+	"context"
+	"iter"
+)
+
+// Iter yields every InputEvent until ctx is done or the gamepad is closed, built on Subscribe:
+//
+//	for e := range g.Iter(ctx) {
+//		...
+//	}
+func (g *Gamepad) Iter(ctx context.Context) iter.Seq[InputEvent] {
+	return func(yield func(InputEvent) bool) {
+		events, unsubscribe := g.Subscribe()
+		defer unsubscribe()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok || !yield(e) {
+					return
+				}
+			}
+		}
+	}
+}
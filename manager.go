@@ -0,0 +1,223 @@
+package gamepad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"sync"
+	"time"
+)
+
+// lifecycleBuffer is how many lifecycle events wait for the reader before the sender blocks
+const lifecycleBuffer = 16
+
+type LifecycleKind int
+
+const (
+	DeviceConnected LifecycleKind = iota
+	DeviceDisconnected
+	DeviceError
+)
+
+func (k LifecycleKind) String() string {
+	switch k {
+	case DeviceConnected:
+		return "Connected"
+	case DeviceDisconnected:
+		return "Disconnected"
+	case DeviceError:
+		return "Error"
+	}
+	return "Unknown"
+}
+
+// LifecycleEvent is a device coming, going or failing, as reported by GamepadManager.Lifecycle
+type LifecycleEvent struct {
+	Kind   LifecycleKind
+	Device DeviceInfo
+
+	// Gamepad reading the device, nil for an error connecting to it
+	Gamepad *Gamepad
+
+	// Set for DeviceDisconnected
+	Reason DisconnectReason
+
+	// Set for DeviceError
+	Err error
+}
+
+// GamepadManager looks for gamepads as they're plugged in and hands out a Gamepad for each, for applications using several.
+// A lost device's gamepad is closed, once plugged in again the device gets a new one. WithAutoReconnect doesn't apply to
+// managed gamepads for that reason.
+type GamepadManager struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	opts     []option
+	config   ConnectConfig
+	interval time.Duration
+
+	mu       sync.Mutex
+	gamepads map[string]*Gamepad
+	failed   map[string]bool // Devices that failed to connect, reported once until they're gone
+
+	sendMu sync.Mutex
+	closed bool
+	events chan LifecycleEvent
+}
+
+// ErrUnmanageable is returned by NewGamepadManager for an option writing to something only one gamepad can have:
+// WithBinaryLog and WithRecording, whose formats hold a single device's events at a time, and WithUnixSocketBroadcast,
+// whose path only one gamepad can listen on.
+var ErrUnmanageable = errors.New("option can't be shared by several gamepads")
+
+// NewGamepadManager looks for gamepads every interval until ctx is done or Close, opening each with opts and the device's ID
+func NewGamepadManager(ctx context.Context, interval time.Duration, opts ...option) (*GamepadManager, error) {
+	g := newGamepad(opts...)
+	switch {
+	case g.binaryLog != nil:
+		return nil, fmt.Errorf("WithBinaryLog: %w", ErrUnmanageable)
+	case g.recorder != nil:
+		return nil, fmt.Errorf("WithRecording: %w", ErrUnmanageable)
+	case g.socketPath != "":
+		return nil, fmt.Errorf("WithUnixSocketBroadcast: %w", ErrUnmanageable)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	m := &GamepadManager{
+		ctx:      ctx,
+		cancel:   cancel,
+		opts:     opts,
+		config:   g.connectConfig,
+		interval: interval,
+		gamepads: make(map[string]*Gamepad),
+		failed:   make(map[string]bool),
+		events:   make(chan LifecycleEvent, lifecycleBuffer),
+	}
+	go m.discover()
+	return m, nil
+}
+
+// Lifecycle receives every device connecting, disconnecting or failing. A DeviceConnected event carries the new Gamepad.
+// It must be read, events wait for room rather than being dropped. The channel is closed on Close.
+func (m *GamepadManager) Lifecycle() <-chan LifecycleEvent {
+	return m.events
+}
+
+// Gamepads returns the gamepads currently connected
+func (m *GamepadManager) Gamepads() []*Gamepad {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	gamepads := make([]*Gamepad, 0, len(m.gamepads))
+	for _, g := range m.gamepads {
+		gamepads = append(gamepads, g)
+	}
+	return gamepads
+}
+
+// Close stops looking for devices and closes every gamepad handed out
+func (m *GamepadManager) Close() error {
+	m.cancel()
+
+	m.sendMu.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.events)
+	}
+	m.sendMu.Unlock()
+
+	for _, g := range m.Gamepads() {
+		_ = g.Close()
+	}
+	return nil
+}
+
+func (m *GamepadManager) discover() {
+	for {
+		m.scan()
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(m.interval):
+		}
+	}
+}
+
+// scan opens a gamepad for every device that doesn't have one
+func (m *GamepadManager) scan() {
+	devices, err := Devices(m.config)
+	if err != nil {
+		m.emit(LifecycleEvent{Kind: DeviceError, Err: fmt.Errorf("listing devices: %w", err)})
+		return
+	}
+
+	present := make(map[string]bool, len(devices))
+	for _, info := range devices {
+		present[info.ID] = true
+
+		m.mu.Lock()
+		_, open := m.gamepads[info.ID]
+		failed := m.failed[info.ID]
+		m.mu.Unlock()
+		if open || failed {
+			continue
+		}
+
+		// The gamepad is handed out by managed before its event loop starts, so a device lost right away is already known
+		opts := append(append([]option{}, m.opts...), m.managed(info))
+		if _, err := NewGamepad(m.ctx, opts...); err != nil {
+			m.mu.Lock()
+			m.failed[info.ID] = true
+			m.mu.Unlock()
+			m.emit(LifecycleEvent{Kind: DeviceError, Device: info, Err: err})
+		}
+	}
+
+	m.mu.Lock()
+	for id := range m.failed {
+		if !present[id] {
+			delete(m.failed, id)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// managed ties a gamepad to its device, reporting its lifecycle and forgetting it once the device is lost
+func (m *GamepadManager) managed(info DeviceInfo) option {
+	return func(g *Gamepad) {
+		g.connectConfig.DeviceID = info.ID
+		g.reconnect = 0
+		g.lifecycle = func(e LifecycleEvent) {
+			e.Device = info
+			m.emit(e)
+		}
+		g.ready = func() {
+			m.mu.Lock()
+			m.gamepads[info.ID] = g
+			m.mu.Unlock()
+			m.emit(LifecycleEvent{Kind: DeviceConnected, Device: info, Gamepad: g})
+		}
+		g.lost = func() {
+			m.mu.Lock()
+			if m.gamepads[info.ID] == g {
+				delete(m.gamepads, info.ID)
+			}
+			m.mu.Unlock()
+			go g.Close()
+		}
+	}
+}
+
+func (m *GamepadManager) emit(e LifecycleEvent) {
+	m.sendMu.Lock()
+	defer m.sendMu.Unlock()
+	if m.closed {
+		return
+	}
+
+	select {
+	case <-m.ctx.Done():
+	case m.events <- e:
+	}
+}
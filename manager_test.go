@@ -0,0 +1,22 @@
+package gamepad
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestManagerRejectsSharedOutputs(t *testing.T) {
+	var buf bytes.Buffer
+	for name, opt := range map[string]option{
+		"binary log":       WithBinaryLog(&buf),
+		"recording":        WithRecording(&buf),
+		"socket broadcast": WithUnixSocketBroadcast("/tmp/gamepad.sock"),
+	} {
+		if _, err := NewGamepadManager(context.Background(), time.Second, opt); !errors.Is(err, ErrUnmanageable) {
+			t.Errorf("%v: got %v, want ErrUnmanageable", name, err)
+		}
+	}
+}
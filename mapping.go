@@ -0,0 +1,165 @@
+package gamepad
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Mapping files are JSON objects from an input to a Resolved name, inputs are written as "button:<index>" or "axis:<index>":
+//
+//	{"button:0": "CrossButton", "axis:6": "DPadXAxis"}
+
+// LoadMapping reads a mapping file, see SaveMapping for the format
+func LoadMapping(r io.Reader) (InputMapping, error) {
+	var file map[string]string
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+
+	m := make(InputMapping, len(file))
+	for key, name := range file {
+		in, err := parseInput(key)
+		if err != nil {
+			return nil, err
+		}
+		resolved, ok := ParseResolved(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown target %q for %v", name, key)
+		}
+		m[in] = resolved
+	}
+	return m, nil
+}
+
+// SaveMapping writes m as an indented mapping file that LoadMapping reads back
+func SaveMapping(w io.Writer, m InputMapping) error {
+	file := make(map[string]string, len(m))
+	for in, resolved := range m {
+		file[formatInput(in)] = resolved.String()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
+
+// ValidateMapping reports inputs that map to the same axis, an axis has a single source so all but one would fight over its value.
+// Several inputs mapping to one button is allowed, the button is down while any of them is.
+func ValidateMapping(m InputMapping) error {
+	sources := make(map[Resolved][]string)
+	for in, resolved := range m {
+		if !resolved.IsAxis() {
+			continue
+		}
+		sources[resolved] = append(sources[resolved], formatInput(in))
+	}
+
+	var overlaps []string
+	for resolved, inputs := range sources {
+		if len(inputs) < 2 {
+			continue
+		}
+		sort.Strings(inputs)
+		overlaps = append(overlaps, fmt.Sprintf("%v from %v", resolved, strings.Join(inputs, ", ")))
+	}
+	if len(overlaps) == 0 {
+		return nil
+	}
+	sort.Strings(overlaps)
+	return fmt.Errorf("overlapping mapping entries: %v", strings.Join(overlaps, "; "))
+}
+
+func formatInput(in Input) string {
+	kind := "button"
+	if in.Type == InputTypeAxis {
+		kind = "axis"
+	}
+	return kind + ":" + strconv.Itoa(int(in.Value))
+}
+
+func parseInput(s string) (Input, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return Input{}, fmt.Errorf("invalid input %q, want button:<index> or axis:<index>", s)
+	}
+	kind, index := parts[0], parts[1]
+
+	v, err := strconv.ParseUint(index, 10, 8)
+	if err != nil {
+		return Input{}, fmt.Errorf("invalid input index %q: %v", s, err)
+	}
+
+	switch kind {
+	case "button":
+		return Input{Type: InputTypeButton, Value: uint8(v)}, nil
+	case "axis":
+		return Input{Type: InputTypeAxis, Value: uint8(v)}, nil
+	}
+	return Input{}, fmt.Errorf("invalid input type %q, want button or axis", kind)
+}
+
+// WatchMappingFile applies the mapping file at path now and again whenever the process receives SIGHUP, until the gamepad is closed.
+// A file that fails to load on reload is reported through OnError and the current mapping is kept.
+func (g *Gamepad) WatchMappingFile(path string) error {
+	if err := g.loadMappingFile(path); err != nil {
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-g.ctx.Done():
+				return
+			case <-hup:
+				if err := g.loadMappingFile(path); err != nil {
+					log.Printf("Mapping reload failed, err: %v", err)
+					if g.errorHandler != nil {
+						g.errorHandler(err)
+					}
+					continue
+				}
+				g.debugLn(fmt.Sprintf("Mapping reloaded from %v\n", path))
+			}
+		}
+	}()
+	return nil
+}
+
+func (g *Gamepad) loadMappingFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	m, err := LoadMapping(f)
+	if err != nil {
+		return fmt.Errorf("%v: %v", path, err)
+	}
+	if g.strictMapping {
+		if err := ValidateMapping(m); err != nil {
+			return fmt.Errorf("%v: %v", path, err)
+		}
+	}
+
+	// Kept as the custom mapping so a reconnect doesn't fall back to DriverMapping
+	g.mu.Lock()
+	g.inputMapping = m
+	g.customMapping = m
+	g.axisMapping = g.customAxes.Copy()
+	g.mu.Unlock()
+	g.checkMapping()
+	return nil
+}
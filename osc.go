@@ -0,0 +1,94 @@
+package gamepad
+
+import (
+	"encoding/binary"
+	"fmt"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"math"
+	"net"
+	"strings"
+)
+
+// oscGroups names the axis groups in OSC addresses
+var oscGroups = map[AxisGroup]string{
+	DPadGroup:         "dpad",
+	LeftStickGroup:    "leftstick",
+	RightStickGroup:   "rightstick",
+	LeftTriggerGroup:  "l2",
+	RightTriggerGroup: "r2",
+	TouchpadGroup:     "touchpad",
+}
+
+// oscAddress is the OSC address of an event, e.g. /gamepad/leftstick or /gamepad/cross. A trigger pressed as a button
+// is /gamepad/l2/button so it doesn't share /gamepad/l2 with the trigger's analog value.
+func oscAddress(e InputEvent) string {
+	if e.Kind == AxisInput {
+		return "/gamepad/" + oscGroups[e.Group]
+	}
+	switch e.Button {
+	case L2Axis:
+		return "/gamepad/" + oscGroups[LeftTriggerGroup] + "/button"
+	case R2Axis:
+		return "/gamepad/" + oscGroups[RightTriggerGroup] + "/button"
+	}
+	name := strings.TrimSuffix(strings.TrimSuffix(e.Button.String(), "Button"), "Axis")
+	return "/gamepad/" + strings.ToLower(name)
+}
+
+// oscMessage encodes an OSC message with float32 arguments
+func oscMessage(address string, args ...float32) []byte {
+	tags := "," + strings.Repeat("f", len(args))
+
+	msg := oscString(nil, address)
+	msg = oscString(msg, tags)
+	var arg [4]byte
+	for _, a := range args {
+		binary.BigEndian.PutUint32(arg[:], math.Float32bits(a))
+		msg = append(msg, arg[:]...)
+	}
+	return msg
+}
+
+// oscString appends s null terminated and padded to a multiple of 4 bytes
+func oscString(b []byte, s string) []byte {
+	b = append(b, s...)
+	return append(b, make([]byte, 4-len(s)%4)...)
+}
+
+// streamOSC sends every event as an OSC message over UDP to addr: sticks, dpad and touchpad as /gamepad/<group> x y,
+// triggers as /gamepad/l2 or /gamepad/r2 with their 0..1 value and buttons as /gamepad/<button> 1 on Down and 0 on Up,
+// the triggers pressed as buttons on /gamepad/l2/button and /gamepad/r2/button.
+func (g *Gamepad) streamOSC(addr string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+
+	events, unsubscribe := g.Subscribe()
+	go func() {
+		defer conn.Close()
+		defer unsubscribe()
+
+		for e := range events {
+			var msg []byte
+			switch {
+			case e.Kind == AxisInput && (e.Group == LeftTriggerGroup || e.Group == RightTriggerGroup):
+				msg = oscMessage(oscAddress(e), e.X)
+			case e.Kind == AxisInput:
+				msg = oscMessage(oscAddress(e), e.X, e.Y)
+			case e.Event == DownEvent:
+				msg = oscMessage(oscAddress(e), 1)
+			case e.Event == UpEvent:
+				msg = oscMessage(oscAddress(e), 0)
+			default:
+				continue
+			}
+
+			// UDP has no one to wait for, a failed send only loses this message
+			if _, err := conn.Write(msg); err != nil {
+				g.debugLn(fmt.Sprintf("OSC send failed: %v\n", err))
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,92 @@
+package gamepad
+
+type pauseRequest struct {
+	paused bool
+	done   chan struct{}
+}
+
+// WithPauseEmitsNeutral makes Pause deliver neutral input before going quiet, centered sticks, released triggers and an
+// UpEvent for every button down, so nothing downstream is left holding the last value. Resume re-delivers the real state.
+func WithPauseEmitsNeutral() option {
+	return func(gamepad *Gamepad) {
+		gamepad.pauseNeutral = true
+	}
+}
+
+// Pause stops delivering input to handlers and subscribers until Resume, the device is still read and tracked meanwhile.
+// It's carried out by the event loop and waits for it.
+func (g *Gamepad) Pause() {
+	g.requestPause(true)
+}
+
+// Resume delivers input again after Pause
+func (g *Gamepad) Resume() {
+	g.requestPause(false)
+}
+
+// Paused reports whether input is paused
+func (g *Gamepad) Paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.paused
+}
+
+func (g *Gamepad) requestPause(paused bool) {
+	req := pauseRequest{paused: paused, done: make(chan struct{})}
+	select {
+	case <-g.ctx.Done():
+		return
+	case g.pauseCh <- req:
+	}
+
+	select {
+	case <-g.ctx.Done():
+	case <-req.done:
+	}
+}
+
+// setPaused switches pausing on the event loop. Neutral input goes out before pausing and the real state after resuming,
+// so both pass the pause check.
+func (g *Gamepad) setPaused(paused bool) {
+	if g.Paused() == paused {
+		return
+	}
+
+	if !paused {
+		g.mu.Lock()
+		g.paused = false
+		g.mu.Unlock()
+	}
+
+	if g.pauseNeutral {
+		if paused {
+			g.emitNeutral()
+		} else {
+			g.flush()
+			for _, b := range g.PressedButtons() {
+				_ = g.Inject(b, DownEvent)
+			}
+		}
+	}
+
+	if paused {
+		g.mu.Lock()
+		g.paused = true
+		g.mu.Unlock()
+	}
+}
+
+// emitNeutral delivers centered sticks, released triggers and an UpEvent for every button down. The stick mouse is
+// stopped too, it moves from the last right stick position.
+func (g *Gamepad) emitNeutral() {
+	g.mu.Lock()
+	g.rightStick = [2]float64{}
+	g.mu.Unlock()
+
+	for _, group := range []AxisGroup{DPadGroup, LeftStickGroup, RightStickGroup, LeftTriggerGroup, RightTriggerGroup} {
+		g.InjectAxis(group, 0, 0)
+	}
+	for _, b := range g.PressedButtons() {
+		_ = g.Inject(b, UpEvent)
+	}
+}
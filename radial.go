@@ -0,0 +1,127 @@
+package gamepad
+
+import (
+	"context"
+	"errors"
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"math"
+)
+
+// The left stick must be pushed past this to highlight a slice of a radial menu
+const radialDeadzone = 0.5
+
+// RadialMenu is a pie menu on a gamepad: hold the button to open it, point the left stick at a slice and release to
+// select it. Slices are numbered clockwise from 0 at the top. Set the handlers, then Run it.
+type RadialMenu struct {
+	g      *Gamepad
+	button Resolved
+	slices int
+
+	openHandler      func()
+	highlightHandler func(slice int)
+	selectHandler    func(slice int)
+	cancelHandler    func()
+}
+
+// NewRadialMenu makes a menu of slices opened by holding button
+func NewRadialMenu(g *Gamepad, button Resolved, slices int) *RadialMenu {
+	return &RadialMenu{g: g, button: button, slices: slices}
+}
+
+// OnOpen is called when the button has been held for the hold duration and the menu opens
+func (m *RadialMenu) OnOpen(h func()) {
+	m.openHandler = h
+}
+
+// OnHighlight is called when the stick points at another slice while the menu is open
+func (m *RadialMenu) OnHighlight(h func(slice int)) {
+	m.highlightHandler = h
+}
+
+// OnSelect is called with the highlighted slice when the button is released
+func (m *RadialMenu) OnSelect(h func(slice int)) {
+	m.selectHandler = h
+}
+
+// OnCancel is called when the button is released without a slice highlighted
+func (m *RadialMenu) OnCancel(h func()) {
+	m.cancelHandler = h
+}
+
+// Run drives the menu from the gamepad's events until ctx is done, returning ctx.Err(), or the gamepad is closed
+func (m *RadialMenu) Run(ctx context.Context) error {
+	if m.slices <= 0 {
+		return errors.New("radial menu needs at least one slice")
+	}
+
+	events, unsubscribe := m.g.Subscribe()
+	defer unsubscribe()
+
+	// The hold timer only runs for buttons something wants hold events of
+	m.g.mu.Lock()
+	m.g.holdWanted[m.button]++
+	m.g.mu.Unlock()
+	defer func() {
+		m.g.mu.Lock()
+		m.g.holdWanted[m.button]--
+		m.g.mu.Unlock()
+	}()
+
+	open := false
+	highlighted := -1
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("gamepad closed")
+			}
+
+			switch {
+			case e.Kind == ButtonInput && e.Button == m.button && e.Event == PressAndHoldEvent:
+				open, highlighted = true, -1
+				if m.openHandler != nil {
+					m.openHandler()
+				}
+
+			case e.Kind == ButtonInput && e.Button == m.button && e.Event == UpEvent && open:
+				open = false
+				if highlighted >= 0 && m.selectHandler != nil {
+					m.selectHandler(highlighted)
+				} else if highlighted < 0 && m.cancelHandler != nil {
+					m.cancelHandler()
+				}
+
+			case e.Kind == AxisInput && e.Group == LeftStickGroup && open:
+				slice := m.slice(float64(e.X), float64(e.Y))
+				if slice >= 0 && slice != highlighted {
+					highlighted = slice
+					if m.highlightHandler != nil {
+						m.highlightHandler(slice)
+					}
+				}
+			}
+		}
+	}
+}
+
+// slice is the slice the stick points at, -1 inside the deadzone. The published y follows WithInvertedY, it's turned
+// back to physical up like OnLeftStick8Way.
+func (m *RadialMenu) slice(x, y float64) int {
+	up := y * YAxisUp
+	if m.g.invertY {
+		up = -up
+	}
+	if math.Hypot(x, up) < radialDeadzone {
+		return -1
+	}
+
+	// Clockwise from the top, each slice centered on its direction
+	angle := math.Atan2(x, up)
+	if angle < 0 {
+		angle += 2 * math.Pi
+	}
+	width := 2 * math.Pi / float64(m.slices)
+	return int(math.Floor(angle/width+0.5)) % m.slices
+}
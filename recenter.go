@@ -0,0 +1,57 @@
+package gamepad
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// A stick moving less than this, normalized, counts as still
+	recenterStillRadius = 0.02
+
+	// A stick further than this from the physical center is being pushed, not drifting
+	recenterMaxOffset = 0.25
+
+	// How long a stick must be still before its position is taken as the center
+	recenterRestTime = time.Second
+)
+
+// drift is the center learned for a stick, only touched by the event loop
+type drift struct {
+	center [2]float64
+	still  [2]float64 // Where the stick came to rest
+	since  time.Time  // When it came to rest
+}
+
+// WithAutoRecenter slowly moves the assumed center of each stick towards where it rests, for old sticks whose center
+// wanders. Once a stick has been still near the center for a second, every report moves the center learnRate, in 0..1,
+// of the way to it. Nothing is learned while the stick is being pushed. The learned center is forgotten on reconnect.
+func WithAutoRecenter(learnRate float32) option {
+	return func(gamepad *Gamepad) {
+		gamepad.recenterRate = math.Min(math.Max(float64(learnRate), 0), 1)
+		gamepad.drifts = make(map[AxisGroup]*drift)
+	}
+}
+
+// recenter learns a stick's center from a normalized position at rest and returns the position relative to it
+func (g *Gamepad) recenter(group AxisGroup, x, y float64) (float64, float64) {
+	if g.drifts == nil || (group != LeftStickGroup && group != RightStickGroup) {
+		return x, y
+	}
+
+	d, ok := g.drifts[group]
+	if !ok {
+		d = &drift{}
+		g.drifts[group] = d
+	}
+
+	now := time.Now()
+	switch {
+	case math.Hypot(x, y) > recenterMaxOffset, math.Hypot(x-d.still[0], y-d.still[1]) > recenterStillRadius:
+		d.still, d.since = [2]float64{x, y}, now
+	case now.Sub(d.since) >= recenterRestTime:
+		d.center[0] += g.recenterRate * (x - d.center[0])
+		d.center[1] += g.recenterRate * (y - d.center[1])
+	}
+	return x - d.center[0], y - d.center[1]
+}
@@ -0,0 +1,171 @@
+package gamepad
+
+import (
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"time"
+)
+
+// SequenceStep is one input of a sequence for OnSequence, a direction, a button press or a button pressed while holding
+// a direction, e.g. forward + punch
+type SequenceStep struct {
+	// Direction of the dpad or left stick, Neutral for a step that's only buttons
+	Direction Direction8
+
+	// Buttons pressed together, the step happens when the last of them goes down. Empty for a step that's only a direction.
+	Buttons []Resolved
+}
+
+// DirectionStep is a step moving the dpad or left stick to d
+func DirectionStep(d Direction8) SequenceStep {
+	return SequenceStep{Direction: d}
+}
+
+// ButtonStep is a step pressing buttons together
+func ButtonStep(buttons ...Resolved) SequenceStep {
+	return SequenceStep{Buttons: buttons}
+}
+
+type sequence struct {
+	steps   []SequenceStep
+	within  time.Duration
+	handler func()
+	buttons map[Resolved]bool // Every button in the steps, other buttons don't interrupt the sequence
+
+	next    int       // Index of the step expected next
+	started time.Time // When the first step happened
+}
+
+// seqInput is a direction change or button press as seen by sequences
+type seqInput struct {
+	direction Direction8
+	button    Resolved
+	isButton  bool
+	when      time.Time
+}
+
+// OnSequence calls h when the steps happen in order within the given time of the first, e.g. down, down-forward,
+// forward + punch for a special move. Directions are physical, compass points of the dpad or left stick snapped like
+// OnLeftStick8Way, so pick East or West for forward by which way the player faces. Any other direction, or a button of
+// the sequence out of order, starts the sequence over, other buttons and returning to neutral don't.
+func (g *Gamepad) OnSequence(seq []SequenceStep, within time.Duration, h func()) {
+	if len(seq) == 0 {
+		return
+	}
+
+	s := &sequence{
+		steps:   append([]SequenceStep(nil), seq...),
+		within:  within,
+		handler: h,
+		buttons: make(map[Resolved]bool),
+	}
+	for _, step := range seq {
+		for _, b := range step.Buttons {
+			s.buttons[b] = true
+		}
+	}
+
+	g.mu.Lock()
+	g.sequences = append(g.sequences, s)
+	g.mu.Unlock()
+}
+
+// feedSequences advances the sequences with a published event and calls the handlers of those it completes
+func (g *Gamepad) feedSequences(e InputEvent) {
+	g.mu.Lock()
+	if len(g.sequences) == 0 {
+		g.mu.Unlock()
+		return
+	}
+
+	var in seqInput
+	switch {
+	case e.Kind == ButtonInput && e.Event == DownEvent:
+		g.seqDown[e.Button] = true
+		in = seqInput{button: e.Button, isButton: true, when: e.When}
+	case e.Kind == ButtonInput && e.Event == UpEvent:
+		delete(g.seqDown, e.Button)
+		g.mu.Unlock()
+		return
+	case e.Kind == AxisInput && (e.Group == DPadGroup || e.Group == LeftStickGroup):
+		// Undo the inversion so directions are physical
+		up := float64(e.Y) * YAxisUp
+		if g.invertY {
+			up = -up
+		}
+		dir := snap8Way(float64(e.X), up, float64(g.deadzone8Way))
+		if dir == g.seqDirs[e.Group] {
+			g.mu.Unlock()
+			return
+		}
+		g.seqDirs[e.Group] = dir
+		g.seqDir = dir
+		if dir == Neutral {
+			g.mu.Unlock()
+			return
+		}
+		in = seqInput{direction: dir, when: e.When}
+	default:
+		g.mu.Unlock()
+		return
+	}
+
+	var completed []func()
+	for _, s := range g.sequences {
+		if s.feed(in, g.seqDir, g.seqDown) {
+			completed = append(completed, s.handler)
+		}
+	}
+	g.mu.Unlock()
+
+	for _, h := range completed {
+		h()
+	}
+}
+
+// feed advances the sequence with an input, reporting whether it completed it
+func (s *sequence) feed(in seqInput, dir Direction8, down map[Resolved]bool) bool {
+	if in.isButton && !s.buttons[in.button] {
+		return false
+	}
+	if s.next > 0 && in.when.Sub(s.started) > s.within {
+		s.next = 0
+	}
+
+	// Reaching the direction of a step pressing buttons while holding it is on the way, not a step of its own
+	if !in.isButton && s.steps[s.next].Direction == in.direction && len(s.steps[s.next].Buttons) > 0 {
+		return false
+	}
+
+	if !s.steps[s.next].matches(in, dir, down) {
+		s.next = 0
+		if !s.steps[0].matches(in, dir, down) {
+			return false
+		}
+	}
+
+	if s.next == 0 {
+		s.started = in.when
+	}
+	s.next++
+	if s.next < len(s.steps) {
+		return false
+	}
+	s.next = 0
+	return true
+}
+
+func (step SequenceStep) matches(in seqInput, dir Direction8, down map[Resolved]bool) bool {
+	if !in.isButton {
+		return len(step.Buttons) == 0 && step.Direction == in.direction
+	}
+
+	if !includesResolved(step.Buttons, in.button) {
+		return false
+	}
+	for _, b := range step.Buttons {
+		if !down[b] {
+			return false
+		}
+	}
+	return step.Direction == Neutral || step.Direction == dir
+}
@@ -0,0 +1,73 @@
+package gamepad
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+// broadcast listens on a Unix domain socket and streams every InputEvent to each connected client.
+// Each event is framed as a 4 byte big-endian length followed by that many bytes of JSON.
+func (g *Gamepad) broadcast(path string) error {
+	removeStaleSocket(path)
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-g.ctx.Done()
+		_ = l.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go g.serveClient(conn)
+		}
+	}()
+	return nil
+}
+
+// removeStaleSocket removes a socket at path left behind by a process that didn't shut down cleanly, which would make
+// Listen fail. A socket something still listens on, or a file that isn't a socket, is left for Listen to report.
+func removeStaleSocket(path string) {
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return
+	}
+	_ = os.Remove(path)
+}
+
+func (g *Gamepad) serveClient(conn net.Conn) {
+	defer conn.Close()
+
+	events, unsubscribe := g.Subscribe()
+	defer unsubscribe()
+
+	g.debugLn(fmt.Sprintf("Socket client connected: %v\n", conn.RemoteAddr()))
+
+	var size [4]byte
+	for e := range events {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			g.debugLn(err.Error())
+			continue
+		}
+
+		binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+		if _, err := conn.Write(append(size[:], payload...)); err != nil {
+			g.debugLn(fmt.Sprintf("Socket client gone: %v\n", err))
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package gamepad
+
+import "fmt"
+
+const subscriberBuffer = 64
+
+type subscriber struct {
+	ch      chan InputEvent
+	dropped uint64
+}
+
+// Subscribe returns a channel receiving every event the gamepad produces, and a func to stop receiving.
+// Each subscriber has its own buffer, events are dropped for a subscriber that falls behind rather than blocking others.
+// The channel is closed on unsubscribe or Close.
+func (g *Gamepad) Subscribe() (<-chan InputEvent, func()) {
+	sub := &subscriber{
+		ch: make(chan InputEvent, subscriberBuffer),
+	}
+
+	g.mu.Lock()
+	g.subscribers[sub] = struct{}{}
+	g.mu.Unlock()
+
+	return sub.ch, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if _, ok := g.subscribers[sub]; ok {
+			delete(g.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// DroppedSubscriberEvents is the number of events dropped for subscribers that fell behind, summed over every subscriber
+// the gamepad has had. Events dropped before reaching the gamepad are counted by DroppedEvents.
+func (g *Gamepad) DroppedSubscriberEvents() uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.subDropped
+}
+
+// publish hands an event to OnEverything and all subscribers, reporting whether anyone was listening
+func (g *Gamepad) publish(e InputEvent) bool {
+	g.mu.Lock()
+	if e.When.IsZero() {
+		e.When = g.stamp()
+	}
+	g.seq++
+	e.Seq = g.seq
+	listening := len(g.subscribers) > 0 || g.frameRate > 0
+	g.accumulate(e)
+	for sub := range g.subscribers {
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped++
+			g.subDropped++
+			if g.debug {
+				g.debugLn(fmt.Sprintf("Subscriber behind, dropped: %v\n", sub.dropped))
+			}
+		}
+	}
+	g.mu.Unlock()
+	g.feedSequences(e)
+
+	if g.everything != nil {
+		g.everything(e)
+		listening = true
+	}
+	return listening
+}
@@ -0,0 +1,105 @@
+package gamepad
+
+import (
+	. "github.com/gooseclip/pi-gamepad/hid"
+	"time"
+)
+
+// A held button in turbo mode auto-fires ClickEvent at this interval by default
+const defaultTurboInterval = time.Second / 10
+
+// faceButtons are the buttons a turbo toggle button can latch
+var faceButtons = []Resolved{CrossButton, CircleButton, SquareButton, TriangleButton}
+
+// WithTurbo puts a button in turbo mode from the start, while it's held it fires ClickEvent every turbo interval
+func WithTurbo(button Resolved) option {
+	return func(gamepad *Gamepad) {
+		gamepad.turbo[button] = true
+	}
+}
+
+// WithTurboInterval sets how often a held turbo button fires, 100ms by default
+func WithTurboInterval(d time.Duration) option {
+	return func(gamepad *Gamepad) {
+		gamepad.turboInterval = d
+	}
+}
+
+// WithTurboToggleButton makes toggle work like an arcade stick's turbo button: press it, then press a face button to
+// switch turbo mode on or off for that button. Pressing toggle again before a face button cancels.
+// The toggle button is taken over by the library, the face button press is delivered as usual.
+func WithTurboToggleButton(toggle Resolved) option {
+	return func(gamepad *Gamepad) {
+		gamepad.turboToggle = &toggle
+	}
+}
+
+// SetTurbo switches turbo mode for a button, a press already held isn't affected
+func (g *Gamepad) SetTurbo(b Resolved, on bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.turbo[b] = on
+}
+
+// Turbo reports whether a button is in turbo mode
+func (g *Gamepad) Turbo(b Resolved) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.turbo[b]
+}
+
+// latchTurbo arms the turbo toggle on a press of it, or latches the face button pressed while it's armed.
+// It reports whether the button is in turbo mode for this press.
+func (g *Gamepad) latchTurbo(resolved Resolved) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.turboToggle != nil {
+		switch {
+		case resolved == *g.turboToggle:
+			g.turboArmed = !g.turboArmed
+			return false
+		case g.turboArmed && includesResolved(faceButtons, resolved):
+			g.turbo[resolved] = !g.turbo[resolved]
+			g.turboArmed = false
+		}
+	}
+	return g.turbo[resolved]
+}
+
+// scheduleTurbo fires ClickEvent every turbo interval until the press it was scheduled for ends
+func (g *Gamepad) scheduleTurbo(resolved Resolved, state *buttonState, btn *button) {
+	interval := g.turboInterval
+	if interval <= 0 {
+		interval = defaultTurboInterval
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if state.turboTimer != nil {
+		state.turboTimer.Stop()
+	}
+
+	press := state.downTime
+	state.turboTimer = time.AfterFunc(interval, func() {
+		g.mu.Lock()
+		active := state.lastPosition == DownPosition && state.downTime.Equal(press)
+		g.mu.Unlock()
+		if !active {
+			return
+		}
+
+		g.fire(resolved, btn, ClickEvent)
+		g.scheduleTurbo(resolved, state, btn)
+	})
+}
+
+func includesResolved(list []Resolved, r Resolved) bool {
+	for _, v := range list {
+		if v == r {
+			return true
+		}
+	}
+	return false
+}